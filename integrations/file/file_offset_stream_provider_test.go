@@ -0,0 +1,68 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"github.com/shpandrak/shpanstream/stream"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStreamFromFileAtOffset(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "lines.txt")
+	require.NoError(t, os.WriteFile(filePath, []byte("one\r\ntwo\n\nfour"), 0o644))
+	lineText := func(l FileLine) string {
+		return string(l.Bytes)
+	}
+
+	type textAndOffset struct {
+		text   string
+		offset int64
+	}
+	lines := stream.Map(StreamFromFileAtOffset(filePath, 0), func(l FileLine) textAndOffset {
+		return textAndOffset{text: string(l.Bytes), offset: l.NextOffset}
+	}).MustCollect()
+	require.Equal(t, []textAndOffset{{"one", 5}, {"two", 9}, {"", 10}, {"four", 14}}, lines)
+
+	require.Equal(t, []string{"", "four"}, stream.Map(StreamFromFileAtOffset(filePath, 9), lineText).MustCollect())
+	require.Empty(t, StreamFromFileAtOffset(filePath, 14).MustCollect())
+	require.Empty(t, StreamFromFileAtOffset(filepath.Join(t.TempDir(), "missing.txt"), 0).MustCollect())
+}
+
+func TestStreamFromFileAtOffset_Resume(t *testing.T) {
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, "lines.txt")
+	require.NoError(t, os.WriteFile(filePath, []byte("a\nb\nc\nd\n"), 0o644))
+	store := stream.NewFileCheckpointStore[int64](filepath.Join(dir, "lines.checkpoint"))
+
+	job := stream.Checkpoint(
+		stream.Resume(store, func(startOffset *int64) stream.Stream[FileLine] {
+			if startOffset == nil {
+				return StreamFromFileAtOffset(filePath, 0)
+			}
+			return StreamFromFileAtOffset(filePath, *startOffset)
+		}),
+		store,
+		func(l FileLine) int64 {
+			return l.NextOffset
+		},
+	)
+
+	boom := errors.New("boom")
+	var processed []string
+	crashed := false
+	process := func(l FileLine) error {
+		// Crashing once while processing "c"
+		if string(l.Bytes) == "c" && !crashed {
+			crashed = true
+			return boom
+		}
+		processed = append(processed, string(l.Bytes))
+		return nil
+	}
+	require.ErrorIs(t, job.ConsumeWithErr(context.Background(), process), boom)
+	require.NoError(t, job.ConsumeWithErr(context.Background(), process))
+	require.Equal(t, []string{"a", "b", "c", "d"}, processed)
+}
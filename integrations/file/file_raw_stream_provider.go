@@ -34,6 +34,25 @@ func StreamFromFile(filePath string, reverse bool) stream.Stream[[]byte] {
 	})
 }
 
+// FileLine is a line read by StreamFromFileAtOffset, along with the offset to resume reading after it.
+type FileLine struct {
+	Bytes []byte
+	// NextOffset is the byte offset in the file right after this line.
+	NextOffset int64
+}
+
+// StreamFromFileAtOffset creates a lazy stream of the lines of a file, starting at the given byte offset (the
+// NextOffset of the last line read, or 0 for the beginning of the file). This allows resuming a consumption,
+// see stream.Checkpoint and stream.Resume.
+func StreamFromFileAtOffset(filePath string, startOffset int64) stream.Stream[FileLine] {
+	return stream.NewStream(&offsetFileStreamProvider{
+		rawFileStreamProvider: rawFileStreamProvider{
+			filePath: filePath,
+		},
+		startOffset: startOffset,
+	})
+}
+
 // Open opens the file for reading and initializes the scanner.
 func (fsp *rawFileStreamProvider) Open(_ context.Context) error {
 	file, err := os.Open(fsp.filePath)
@@ -104,3 +123,41 @@ func (fsp *rawFileStreamProvider) Emit(ctx context.Context) ([]byte, error) {
 		}
 	}
 }
+
+// offsetFileStreamProvider reads lines from a file starting at an offset, keeping track of the offset.
+type offsetFileStreamProvider struct {
+	rawFileStreamProvider
+	startOffset int64
+	offset      int64
+}
+
+// Open opens the file for reading at the start offset and initializes the scanner.
+func (fsp *offsetFileStreamProvider) Open(ctx context.Context) error {
+	err := fsp.rawFileStreamProvider.Open(ctx)
+	if err != nil || fsp.fileMissingHenceEmpty {
+		return err
+	}
+	if _, err := fsp.file.Seek(fsp.startOffset, io.SeekStart); err != nil {
+		fsp.Close()
+		return err
+	}
+	fsp.offset = fsp.startOffset
+	scanner := bufio.NewScanner(fsp.file)
+	scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		advance, token, err := bufio.ScanLines(data, atEOF)
+		// Counting the line terminators as well, so offset is right after the line
+		fsp.offset += int64(advance)
+		return advance, token, err
+	})
+	fsp.scanner = scanner
+	return nil
+}
+
+// Emit reads the next line from the file, along with the offset right after it.
+func (fsp *offsetFileStreamProvider) Emit(ctx context.Context) (FileLine, error) {
+	line, err := fsp.rawFileStreamProvider.Emit(ctx)
+	if err != nil {
+		return FileLine{}, err
+	}
+	return FileLine{Bytes: line, NextOffset: fsp.offset}, nil
+}
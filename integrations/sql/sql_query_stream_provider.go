@@ -7,6 +7,7 @@ import (
 	"github.com/shpandrak/shpanstream/internal/util"
 	"github.com/shpandrak/shpanstream/stream"
 	"io"
+	"slices"
 )
 
 func StreamSqlQuery[T any](
@@ -28,6 +29,25 @@ func StreamSqlQuery[T any](
 
 }
 
+// StreamSqlQueryAfterKey creates a lazy stream of a keyset-paginated query, starting right after the given key,
+// for resuming a consumption (see stream.Checkpoint and stream.Resume, with the key of the last consumed row as
+// the offset). queryFromStart is used when afterKey is nil, and queryAfterKey otherwise, getting the key as its
+// last parameter (after paramVals). Both queries must be ordered by the key, e.g.
+// "SELECT id, name FROM events ORDER BY id" and "SELECT id, name FROM events WHERE id > ? ORDER BY id".
+func StreamSqlQueryAfterKey[T any, K any](
+	dbProvider func() (*sql.DB, error),
+	queryFromStart string,
+	queryAfterKey string,
+	paramVals []any,
+	afterKey *K,
+	scanner func(*sql.Rows) (T, error),
+) stream.Stream[T] {
+	if afterKey == nil {
+		return StreamSqlQuery(dbProvider, queryFromStart, paramVals, scanner)
+	}
+	return StreamSqlQuery(dbProvider, queryAfterKey, append(slices.Clone(paramVals), *afterKey), scanner)
+}
+
 type sqlQueryStreamProvider[T any] struct {
 	db        *sql.DB
 	query     string
@@ -1,11 +1,15 @@
 package sql
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/shpandrak/shpanstream/internal/util"
+	"github.com/shpandrak/shpanstream/stream"
 	"github.com/stretchr/testify/require"
+	"path/filepath"
 	"testing"
 	"time"
 )
@@ -52,3 +56,68 @@ func TestStreamSqlQuery(t *testing.T) {
 
 	require.Equal(t, 1, 1)
 }
+
+func TestStreamSqlQueryAfterKey_Resume(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	_, err = db.Exec(`CREATE TABLE events (id INTEGER PRIMARY KEY, kind TEXT)`)
+	require.NoError(t, err)
+	for i := 1; i <= 100; i++ {
+		kind := "odd"
+		if i%2 == 0 {
+			kind = "even"
+		}
+		_, err = db.Exec(`INSERT INTO events (id, kind) VALUES (?, ?)`, i, kind)
+		require.NoError(t, err)
+	}
+
+	store := stream.NewFileCheckpointStore[int64](filepath.Join(t.TempDir(), "checkpoint.json"))
+	events := stream.Resume(store, func(afterId *int64) stream.Stream[int64] {
+		return StreamSqlQueryAfterKey(
+			func() (*sql.DB, error) {
+				return db, nil
+			},
+			"SELECT id FROM events WHERE kind = ? ORDER BY id",
+			"SELECT id FROM events WHERE kind = ? AND id > ? ORDER BY id",
+			[]any{"even"},
+			afterId,
+			func(rows *sql.Rows) (int64, error) {
+				var id int64
+				err := rows.Scan(&id)
+				return id, err
+			},
+		)
+	})
+	checkpointed := func() stream.Stream[int64] {
+		return stream.Checkpoint(events, store, func(id int64) int64 {
+			return id
+		}, stream.WithCheckpointEveryOption(5))
+	}
+
+	// Crash while consuming id 40, which is consumed again on resume
+	crash := errors.New("crash")
+	var consumed []int64
+	err = checkpointed().ConsumeWithErr(context.Background(), func(id int64) error {
+		if id == 40 {
+			return crash
+		}
+		consumed = append(consumed, id)
+		return nil
+	})
+	require.ErrorIs(t, err, crash)
+
+	resumed, err := checkpointed().Collect(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, int64(40), resumed[0])
+
+	var expected []int64
+	for i := int64(2); i <= 100; i += 2 {
+		expected = append(expected, i)
+	}
+	require.Equal(t, expected, append(consumed, resumed...))
+
+	// Completed, nothing left to resume
+	require.Empty(t, checkpointed().MustCollect())
+}
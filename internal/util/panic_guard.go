@@ -0,0 +1,32 @@
+package util
+
+import (
+	"fmt"
+	"github.com/shpandrak/shpanstream"
+	"log/slog"
+)
+
+// RunWithPanicGuard is the panic guard shared by the stream and lazy packages, for the pipeline consume paths and
+// for the background goroutines of the concurrent operators and evaluations: a panic unwinding out of body is
+// logged with its stack and converted into the shpanstream.PanicError body returns. The completed flag (rather
+// than recover() != nil) is what detects unwinding, so a legacy panic(nil) under GODEBUG=panicnil=1 — where
+// recover() returns nil and thereby cancels the panic — surfaces as an error instead of a clean, silently
+// truncated run. A runtime.Goexit trips the flag too, but the error it fabricates is only assigned to a return
+// value Goexit discards, so it stays harmless.
+func RunWithPanicGuard(body func() error) (err error) {
+	completed := false
+	defer func() {
+		if completed {
+			return
+		}
+		rvr := recover()
+		pe := shpanstream.NewPanicError(rvr)
+		err = pe
+		if rvr != nil {
+			slog.Error(fmt.Sprintf("Panic recovered: %v\n%s", rvr, pe.Stack))
+		}
+	}()
+	err = body()
+	completed = true
+	return err
+}
@@ -0,0 +1,274 @@
+package lazy
+
+import (
+	"context"
+	"errors"
+	"github.com/shpandrak/shpanstream"
+	"github.com/shpandrak/shpanstream/internal/util"
+	"sync"
+	"sync/atomic"
+)
+
+type CombineOption func(*combineConfig)
+
+type combineConfig struct {
+	concurrent bool
+}
+
+// WithConcurrentEvaluationOption evaluates the combined lazies concurrently (each on its own goroutine), rather
+// than one after the other. The first error (or empty value, when it decides the result) cancels the context of
+// the other evaluations, and the combined lazy returns once all the evaluations returned. A panicking lazy fails
+// the combined lazy with a PanicError.
+func WithConcurrentEvaluationOption() CombineOption {
+	return func(cfg *combineConfig) {
+		cfg.concurrent = true
+	}
+}
+
+func newCombineConfig(opts []CombineOption) combineConfig {
+	var cfg combineConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+// combinedEmptyError stops the evaluation of the combined lazies once one of them is empty, carrying its empty
+// value error.
+type combinedEmptyError struct {
+	emptyValueErrSupplier func() error
+}
+
+func (e *combinedEmptyError) Error() string {
+	return "combined lazy is empty"
+}
+
+// combinedEmpty records the empty value error of the input found empty, so getting an empty combined lazy fails
+// with it. Concurrent evaluations of the same combined lazy share it, the last one found empty wins.
+type combinedEmpty struct {
+	emptyValueErrSupplier atomic.Pointer[func() error]
+}
+
+func (ce *combinedEmpty) err() error {
+	if sup := ce.emptyValueErrSupplier.Load(); sup != nil {
+		return (*sup)()
+	}
+	return defaultEmptyValueErr()
+}
+
+// newCombinedLazy creates the combined lazy of fetcher, which fails with a combinedEmptyError when an input is
+// empty.
+func newCombinedLazy[T any](fetcher func(ctx context.Context) (*T, error)) Lazy[T] {
+	empty := &combinedEmpty{}
+	return newLazy(func(ctx context.Context) (*T, error) {
+		v, err := fetcher(ctx)
+		var emptyErr *combinedEmptyError
+		if errors.As(err, &emptyErr) {
+			empty.emptyValueErrSupplier.Store(&emptyErr.emptyValueErrSupplier)
+			return nil, nil
+		}
+		return v, err
+	}, empty.err)
+}
+
+// Zip2 combines two lazies into a lazy of a pair of their values. It is empty if any of them is empty, getting
+// its value then fails with the empty value error of the empty one.
+func Zip2[A any, B any](a Lazy[A], b Lazy[B], opts ...CombineOption) Lazy[shpanstream.Tuple2[A, B]] {
+	cfg := newCombineConfig(opts)
+	return newCombinedLazy(func(ctx context.Context) (*shpanstream.Tuple2[A, B], error) {
+		var av *A
+		var bv *B
+		err := evaluateAll(
+			ctx,
+			cfg.concurrent,
+			func(ctx context.Context) (err error) {
+				av, err = requireValue(ctx, a)
+				return err
+			},
+			func(ctx context.Context) (err error) {
+				bv, err = requireValue(ctx, b)
+				return err
+			},
+		)
+		if err != nil {
+			return nil, err
+		}
+		return &shpanstream.Tuple2[A, B]{A: *av, B: *bv}, nil
+	})
+}
+
+// Zip3 combines three lazies into a lazy of a tuple of their values. It is empty if any of them is empty, getting
+// its value then fails with the empty value error of the empty one.
+func Zip3[A any, B any, C any](
+	a Lazy[A],
+	b Lazy[B],
+	c Lazy[C],
+	opts ...CombineOption,
+) Lazy[shpanstream.Tuple3[A, B, C]] {
+	cfg := newCombineConfig(opts)
+	return newCombinedLazy(func(ctx context.Context) (*shpanstream.Tuple3[A, B, C], error) {
+		var av *A
+		var bv *B
+		var cv *C
+		err := evaluateAll(
+			ctx,
+			cfg.concurrent,
+			func(ctx context.Context) (err error) {
+				av, err = requireValue(ctx, a)
+				return err
+			},
+			func(ctx context.Context) (err error) {
+				bv, err = requireValue(ctx, b)
+				return err
+			},
+			func(ctx context.Context) (err error) {
+				cv, err = requireValue(ctx, c)
+				return err
+			},
+		)
+		if err != nil {
+			return nil, err
+		}
+		return &shpanstream.Tuple3[A, B, C]{A: *av, B: *bv, C: *cv}, nil
+	})
+}
+
+// All combines the lazies into a lazy of all their values, in order. It is empty if any of them is empty, getting
+// its value then fails with the empty value error of the empty one.
+func All[T any](lazies []Lazy[T], opts ...CombineOption) Lazy[[]T] {
+	cfg := newCombineConfig(opts)
+	return newCombinedLazy(func(ctx context.Context) (*[]T, error) {
+		values := make([]T, len(lazies))
+		fetchers := make([]func(ctx context.Context) error, len(lazies))
+		for i, l := range lazies {
+			fetchers[i] = func(ctx context.Context) error {
+				v, err := requireValue(ctx, l)
+				if err != nil {
+					return err
+				}
+				values[i] = *v
+				return nil
+			}
+		}
+		if err := evaluateAll(ctx, cfg.concurrent, fetchers...); err != nil {
+			return nil, err
+		}
+		return &values, nil
+	})
+}
+
+// FirstNonEmpty returns the value of the first lazy (in order) that is not empty, or empty if all of them are.
+// When evaluated sequentially, the lazies after it are not evaluated. When evaluated concurrently, it returns
+// once all the lazies before it are known to be empty, cancelling the rest.
+// An error of a lazy before the first non-empty one fails the result, like in the sequential evaluation.
+// Like Or, getting the value of an empty result fails with the empty value error of the last lazy.
+func FirstNonEmpty[T any](lazies []Lazy[T], opts ...CombineOption) Lazy[T] {
+	cfg := newCombineConfig(opts)
+	emptyValueErrSupplier := defaultEmptyValueErr
+	if len(lazies) > 0 {
+		emptyValueErrSupplier = lazies[len(lazies)-1].emptyValueErrSupplier
+	}
+	return newLazy(func(ctx context.Context) (*T, error) {
+		if !cfg.concurrent {
+			for _, l := range lazies {
+				v, err := l.fetcher(ctx)
+				if err != nil || v != nil {
+					return v, err
+				}
+			}
+			return nil, nil
+		}
+		return firstNonEmptyConcurrently(ctx, lazies)
+	}, emptyValueErrSupplier)
+}
+
+func firstNonEmptyConcurrently[T any](ctx context.Context, lazies []Lazy[T]) (*T, error) {
+	type result struct {
+		i   int
+		v   *T
+		err error
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	defer func() {
+		// Stop the evaluations that are no longer needed, and wait for them
+		cancel()
+		wg.Wait()
+	}()
+
+	resultsChan := make(chan result, len(lazies))
+	for i, l := range lazies {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var v *T
+			err := util.RunWithPanicGuard(func() error {
+				var err error
+				v, err = l.fetcher(ctx)
+				return err
+			})
+			resultsChan <- result{i: i, v: v, err: err}
+		}()
+	}
+
+	results := make([]*result, len(lazies))
+	next := 0
+	for next < len(lazies) {
+		r := <-resultsChan
+		results[r.i] = &r
+		// Deciding once all the lazies before the first non-empty (or failed) one are known to be empty
+		for next < len(lazies) && results[next] != nil {
+			if results[next].err != nil || results[next].v != nil {
+				return results[next].v, results[next].err
+			}
+			next++
+		}
+	}
+	return nil, nil
+}
+
+// evaluateAll runs the fetchers, sequentially or concurrently, returning the first error.
+func evaluateAll(ctx context.Context, concurrent bool, fetchers ...func(ctx context.Context) error) error {
+	if !concurrent {
+		for _, f := range fetchers {
+			if err := f(ctx); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var wg sync.WaitGroup
+	var firstErr error
+	var once sync.Once
+	for _, f := range fetchers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := util.RunWithPanicGuard(func() error { return f(ctx) }); err != nil {
+				once.Do(func() {
+					firstErr = err
+					// Cancel the other evaluations on the first error
+					cancel()
+				})
+			}
+		}()
+	}
+	wg.Wait()
+	return firstErr
+}
+
+// requireValue fetches the value of l, converting an empty value to a combinedEmptyError, to stop evaluating the
+// other lazies.
+func requireValue[T any](ctx context.Context, l Lazy[T]) (*T, error) {
+	v, err := l.fetcher(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if v == nil {
+		return nil, &combinedEmptyError{emptyValueErrSupplier: l.emptyValueErrSupplier}
+	}
+	return v, nil
+}
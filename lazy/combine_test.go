@@ -0,0 +1,148 @@
+package lazy
+
+import (
+	"context"
+	"errors"
+	"github.com/shpandrak/shpanstream"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// blockingLazy blocks until its context is done.
+func blockingLazy[T any]() Lazy[T] {
+	return NewLazy(func(ctx context.Context) (T, error) {
+		<-ctx.Done()
+		var zero T
+		return zero, ctx.Err()
+	})
+}
+
+var combineModes = map[string][]CombineOption{
+	"sequential": nil,
+	"concurrent": {WithConcurrentEvaluationOption()},
+}
+
+func TestZip(t *testing.T) {
+	for name, opts := range combineModes {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, shpanstream.Tuple2[int, string]{A: 1, B: "a"}, Zip2(Just(1), Just("a"), opts...).MustGet())
+			require.Equal(
+				t,
+				shpanstream.Tuple3[int, string, bool]{A: 1, B: "a", C: true},
+				Zip3(Just(1), Just("a"), Just(true), opts...).MustGet(),
+			)
+			require.True(t, Zip2(Just(1), Empty[string](), opts...).MustIsEmpty())
+			require.True(t, Zip3(Just(1), Just("a"), Empty[bool](), opts...).MustIsEmpty())
+
+			boom := errors.New("boom")
+			_, err := Zip2(Just(1), Error[string](boom), opts...).Get(context.Background())
+			require.ErrorIs(t, err, boom)
+		})
+	}
+}
+
+func TestAll(t *testing.T) {
+	for name, opts := range combineModes {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, []int{1, 2, 3}, All([]Lazy[int]{Just(1), Just(2), Just(3)}, opts...).MustGet())
+			require.Equal(t, []int{}, All([]Lazy[int]{}, opts...).MustGet())
+			require.True(t, All([]Lazy[int]{Just(1), Empty[int]()}, opts...).MustIsEmpty())
+		})
+	}
+}
+
+func TestAll_ConcurrentCancelOnFirstError(t *testing.T) {
+	boom := errors.New("boom")
+	_, err := All(
+		[]Lazy[int]{blockingLazy[int](), Error[int](boom), blockingLazy[int]()},
+		WithConcurrentEvaluationOption(),
+	).Get(context.Background())
+	require.ErrorIs(t, err, boom)
+
+	// An empty value decides the result as well
+	require.True(t, Zip2(blockingLazy[int](), Empty[int](), WithConcurrentEvaluationOption()).MustIsEmpty())
+}
+
+func TestFirstNonEmpty(t *testing.T) {
+	boom := errors.New("boom")
+	for name, opts := range combineModes {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, 2, FirstNonEmpty([]Lazy[int]{Empty[int](), Just(2), Just(3)}, opts...).MustGet())
+			require.True(t, FirstNonEmpty([]Lazy[int]{Empty[int](), Empty[int]()}, opts...).MustIsEmpty())
+			require.True(t, FirstNonEmpty([]Lazy[int]{}, opts...).MustIsEmpty())
+
+			// Errors before the first non-empty value fail, and errors after it are ignored
+			_, err := FirstNonEmpty([]Lazy[int]{Error[int](boom), Just(2)}, opts...).Get(context.Background())
+			require.ErrorIs(t, err, boom)
+			require.Equal(t, 1, FirstNonEmpty([]Lazy[int]{Just(1), Error[int](boom)}, opts...).MustGet())
+		})
+	}
+}
+
+func TestFirstNonEmpty_ConcurrentCancelsTheRest(t *testing.T) {
+	require.Equal(
+		t,
+		1,
+		FirstNonEmpty([]Lazy[int]{Empty[int](), Just(1), blockingLazy[int]()}, WithConcurrentEvaluationOption()).MustGet(),
+	)
+}
+
+func TestCombine_EmptyValueErr(t *testing.T) {
+	errNoA := errors.New("no a")
+	errNoB := errors.New("no b")
+	emptyA := Empty[int]().OrElseThrow(func() error { return errNoA })
+	emptyB := Empty[string]().OrElseThrow(func() error { return errNoB })
+	for name, opts := range combineModes {
+		t.Run(name, func(t *testing.T) {
+			_, err := Zip2(Just(1), emptyB, opts...).Get(context.Background())
+			require.ErrorIs(t, err, errNoB)
+			_, err = Zip3(emptyA, Just("a"), Just(true), opts...).Get(context.Background())
+			require.ErrorIs(t, err, errNoA)
+			_, err = All([]Lazy[int]{Just(1), emptyA}, opts...).Get(context.Background())
+			require.ErrorIs(t, err, errNoA)
+
+			// Nested combined lazies keep the error of the empty input
+			_, err = Zip2(Just(1), Zip2(Just(2), emptyB, opts...), opts...).Get(context.Background())
+			require.ErrorIs(t, err, errNoB)
+
+			// Like Or, the error of the last lazy
+			_, err = FirstNonEmpty([]Lazy[int]{Empty[int](), emptyA}, opts...).Get(context.Background())
+			require.ErrorIs(t, err, errNoA)
+		})
+	}
+}
+
+// Under the legacy GODEBUG=panicnil=1 mode, recover() returns nil for panic(nil). A concurrent evaluation and a
+// memoized fetch must still fail with a PanicError rather than succeed with an empty value.
+func TestCombine_LegacyPanicNilSurfacesError(t *testing.T) {
+	t.Setenv("GODEBUG", "panicnil=1")
+	panicking := NewLazyOptional(func(ctx context.Context) (*int, error) {
+		panic(nil)
+	})
+	var pe *PanicError
+	_, err := All([]Lazy[int]{Just(1), panicking}, WithConcurrentEvaluationOption()).Get(context.Background())
+	require.ErrorAs(t, err, &pe)
+
+	_, err = Memoize(panicking).Get(context.Background())
+	require.ErrorAs(t, err, &pe)
+}
+
+func TestCombine_ConcurrentPanic(t *testing.T) {
+	boom := errors.New("lazy boom")
+	panicking := NewLazyOptional(func(ctx context.Context) (*int, error) {
+		panic(boom)
+	})
+	var pe *PanicError
+	_, err := All([]Lazy[int]{Just(1), panicking}, WithConcurrentEvaluationOption()).Get(context.Background())
+	require.ErrorIs(t, err, boom)
+	require.ErrorAs(t, err, &pe)
+	require.NotEmpty(t, pe.Stack)
+
+	_, err = Zip2(blockingLazy[int](), panicking, WithConcurrentEvaluationOption()).Get(context.Background())
+	require.ErrorAs(t, err, &pe)
+
+	_, err = FirstNonEmpty([]Lazy[int]{Empty[int](), panicking, Just(1)}, WithConcurrentEvaluationOption()).
+		Get(context.Background())
+	require.ErrorIs(t, err, boom)
+	require.ErrorAs(t, err, &pe)
+}
@@ -0,0 +1,165 @@
+package lazy
+
+import (
+	"context"
+	"github.com/shpandrak/shpanstream/internal/util"
+	"sync"
+	"time"
+)
+
+type MemoizeOption func(*memoizeConfig)
+
+type memoizeConfig struct {
+	ttl         time.Duration
+	cacheErrors bool
+	clock       Clock
+}
+
+// Clock is the time source of Memoize expiry (see WithMemoizeClockOption). Any stream.Clock, e.g. a
+// stream.ManualClock, is a Clock.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// WithMemoizeTTLOption expires the memoized value once ttl elapsed since it was fetched, so the next Get fetches
+// it again. By default, the value never expires.
+func WithMemoizeTTLOption(ttl time.Duration) MemoizeOption {
+	return func(cfg *memoizeConfig) {
+		cfg.ttl = ttl
+	}
+}
+
+// WithMemoizeCacheErrorsOption memoizes errors as well (until they expire, see WithMemoizeTTLOption). By default,
+// an error is returned only to the callers waiting for the failed fetch, and the next Get fetches again.
+func WithMemoizeCacheErrorsOption() MemoizeOption {
+	return func(cfg *memoizeConfig) {
+		cfg.cacheErrors = true
+	}
+}
+
+// WithMemoizeClockOption sets the clock expiry is measured by (see WithMemoizeTTLOption), default is the system
+// clock.
+func WithMemoizeClockOption(clock Clock) MemoizeOption {
+	return func(cfg *memoizeConfig) {
+		cfg.clock = clock
+	}
+}
+
+// Memoize returns a Lazy that fetches the value of l once, and returns the memoized value (or empty value) on
+// subsequent calls. Concurrent callers share a single in-flight fetch (single-flight).
+// The fetch runs on its own goroutine using the context values and deadline of the caller that started it. A
+// caller whose context is done stops waiting and returns the context error, while the fetch completes for the
+// other callers; once the last waiting caller left, the fetch context is canceled, and the next Get fetches again.
+// A panicking fetch fails with a PanicError.
+func Memoize[T any](l Lazy[T], opts ...MemoizeOption) Lazy[T] {
+	cfg := memoizeConfig{
+		clock: systemClock{},
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	m := &memoized[T]{src: l, cfg: cfg}
+	return newLazy(m.fetch, l.emptyValueErrSupplier)
+}
+
+type memoized[T any] struct {
+	src Lazy[T]
+	cfg memoizeConfig
+
+	mu   sync.Mutex
+	call *memoizeCall[T]
+}
+
+// memoizeCall is a single fetch of the value, the result fields are set before done is closed.
+type memoizeCall[T any] struct {
+	done      chan struct{}
+	v         *T
+	err       error
+	fetchedAt time.Time
+
+	// waiters counts the callers waiting for the fetch, guarded by the memoized lock
+	waiters int
+	cancel  context.CancelFunc
+}
+
+func (m *memoized[T]) fetch(ctx context.Context) (*T, error) {
+	m.mu.Lock()
+	c := m.call
+	if c == nil || m.isStale(c) {
+		c = m.startCall(ctx)
+		m.call = c
+	}
+	c.waiters++
+	m.mu.Unlock()
+
+	select {
+	case <-c.done:
+		m.leave(c)
+		return c.v, c.err
+	case <-ctx.Done():
+		m.leave(c)
+		return nil, ctx.Err()
+	}
+}
+
+// startCall starts fetching the value on its own goroutine, must be called while holding the lock.
+func (m *memoized[T]) startCall(ctx context.Context) *memoizeCall[T] {
+	fetchCtx := context.WithoutCancel(ctx)
+	var cancel context.CancelFunc
+	if deadline, ok := ctx.Deadline(); ok {
+		fetchCtx, cancel = context.WithDeadline(fetchCtx, deadline)
+	} else {
+		fetchCtx, cancel = context.WithCancel(fetchCtx)
+	}
+	c := &memoizeCall[T]{done: make(chan struct{}), cancel: cancel}
+	go func() {
+		defer close(c.done)
+		defer cancel()
+		c.err = util.RunWithPanicGuard(func() error {
+			var err error
+			c.v, err = m.src.fetcher(fetchCtx)
+			return err
+		})
+		c.fetchedAt = m.cfg.clock.Now()
+	}()
+	return c
+}
+
+// leave is called once a caller stops waiting for c, canceling the fetch if it was the last caller waiting for it
+// while still in flight. The canceled call is dropped, so its (context) error is never memoized.
+func (m *memoized[T]) leave(c *memoizeCall[T]) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c.waiters--
+	if c.waiters > 0 {
+		return
+	}
+	select {
+	case <-c.done:
+	default:
+		c.cancel()
+		if m.call == c {
+			m.call = nil
+		}
+	}
+}
+
+// isStale returns whether a completed call should be fetched again, must be called while holding the lock.
+func (m *memoized[T]) isStale(c *memoizeCall[T]) bool {
+	select {
+	case <-c.done:
+	default:
+		// Still in flight
+		return false
+	}
+	if c.err != nil && !m.cfg.cacheErrors {
+		return true
+	}
+	return m.cfg.ttl > 0 && m.cfg.clock.Now().Sub(c.fetchedAt) >= m.cfg.ttl
+}
@@ -0,0 +1,233 @@
+package lazy
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func countingLazy(calls *atomic.Int32) Lazy[int] {
+	return NewLazy(func(ctx context.Context) (int, error) {
+		return int(calls.Add(1)), nil
+	})
+}
+
+func TestMemoize(t *testing.T) {
+	var calls atomic.Int32
+	l := Memoize(countingLazy(&calls))
+	require.Equal(t, 1, l.MustGet())
+	require.Equal(t, 1, l.MustGet())
+	require.EqualValues(t, 1, calls.Load())
+
+	// Empty values are memoized as well
+	var emptyCalls atomic.Int32
+	empty := Memoize(NewLazyOptional(func(ctx context.Context) (*int, error) {
+		emptyCalls.Add(1)
+		return nil, nil
+	}))
+	require.True(t, empty.MustIsEmpty())
+	require.True(t, empty.MustIsEmpty())
+	_, err := empty.Get(context.Background())
+	require.Error(t, err)
+	require.EqualValues(t, 1, emptyCalls.Load())
+}
+
+func TestMemoize_SingleFlight(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	l := Memoize(NewLazy(func(ctx context.Context) (int, error) {
+		calls.Add(1)
+		<-release
+		return 7, nil
+	}))
+
+	var wg sync.WaitGroup
+	results := make([]int, 10)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = l.MustGet()
+		}()
+	}
+	close(release)
+	wg.Wait()
+	require.EqualValues(t, 1, calls.Load())
+	for _, r := range results {
+		require.Equal(t, 7, r)
+	}
+}
+
+// memoizeTestClock is a Clock moved by the test.
+type memoizeTestClock struct {
+	now time.Time
+}
+
+func (c *memoizeTestClock) Now() time.Time {
+	return c.now
+}
+
+func TestMemoize_TTL(t *testing.T) {
+	clock := &memoizeTestClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	var calls atomic.Int32
+	l := Memoize(
+		countingLazy(&calls),
+		WithMemoizeTTLOption(time.Minute),
+		WithMemoizeClockOption(clock),
+	)
+	require.Equal(t, 1, l.MustGet())
+	clock.now = clock.now.Add(59 * time.Second)
+	require.Equal(t, 1, l.MustGet())
+	clock.now = clock.now.Add(time.Second)
+	require.Equal(t, 2, l.MustGet())
+	require.Equal(t, 2, l.MustGet())
+}
+
+func TestMemoize_Errors(t *testing.T) {
+	boom := errors.New("boom")
+	var calls atomic.Int32
+	failing := NewLazy(func(ctx context.Context) (int, error) {
+		calls.Add(1)
+		return 0, boom
+	})
+
+	// By default errors are not memoized
+	l := Memoize(failing)
+	_, err := l.Get(context.Background())
+	require.ErrorIs(t, err, boom)
+	_, err = l.Get(context.Background())
+	require.ErrorIs(t, err, boom)
+	require.EqualValues(t, 2, calls.Load())
+
+	calls.Store(0)
+	l = Memoize(failing, WithMemoizeCacheErrorsOption())
+	_, err = l.Get(context.Background())
+	require.ErrorIs(t, err, boom)
+	_, err = l.Get(context.Background())
+	require.ErrorIs(t, err, boom)
+	require.EqualValues(t, 1, calls.Load())
+}
+
+func TestMemoize_CallerCancellation(t *testing.T) {
+	release := make(chan struct{})
+	l := Memoize(NewLazy(func(ctx context.Context) (int, error) {
+		select {
+		case <-release:
+			return 7, nil
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+	}))
+
+	// The first caller gives up, the fetch completes for the next one
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := l.Get(ctx)
+	require.ErrorIs(t, err, context.Canceled)
+	close(release)
+	require.Equal(t, 7, l.MustGet())
+}
+
+// waitingCtx signals waiting once Done is called, i.e. once the caller registered and waits for the fetch.
+type waitingCtx struct {
+	context.Context
+	once    sync.Once
+	waiting chan struct{}
+}
+
+func newWaitingCtx(ctx context.Context) *waitingCtx {
+	return &waitingCtx{Context: ctx, waiting: make(chan struct{})}
+}
+
+func (c *waitingCtx) Done() <-chan struct{} {
+	c.once.Do(func() {
+		close(c.waiting)
+	})
+	return c.Context.Done()
+}
+
+func TestMemoize_LastWaiterCancelsFetch(t *testing.T) {
+	var calls atomic.Int32
+	fetchCtxs := make(chan context.Context, 2)
+	release := make(chan struct{})
+	l := Memoize(NewLazy(func(ctx context.Context) (int, error) {
+		calls.Add(1)
+		fetchCtxs <- ctx
+		select {
+		case <-release:
+			return 7, nil
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+	}), WithMemoizeCacheErrorsOption())
+
+	// The first caller leaves while the second one still waits, the fetch goes on
+	ctx1, cancel1 := context.WithCancel(context.Background())
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	defer cancel2()
+	errs := make(chan error, 2)
+	go func() {
+		_, err := l.Get(ctx1)
+		errs <- err
+	}()
+	fetchCtx := <-fetchCtxs
+	waiting2 := newWaitingCtx(ctx2)
+	go func() {
+		_, err := l.Get(waiting2)
+		errs <- err
+	}()
+	<-waiting2.waiting
+	cancel1()
+	require.ErrorIs(t, <-errs, context.Canceled)
+	require.NoError(t, fetchCtx.Err())
+
+	// The last caller leaves, the fetch is canceled
+	cancel2()
+	require.ErrorIs(t, <-errs, context.Canceled)
+	<-fetchCtx.Done()
+	require.ErrorIs(t, fetchCtx.Err(), context.Canceled)
+
+	// The canceled fetch is not memoized, even when caching errors
+	close(release)
+	require.Equal(t, 7, l.MustGet())
+	require.EqualValues(t, 2, calls.Load())
+}
+
+func TestMemoize_FetchKeepsCallerDeadline(t *testing.T) {
+	deadline := time.Now().Add(time.Hour)
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+	l := Memoize(NewLazy(func(ctx context.Context) (time.Time, error) {
+		d, ok := ctx.Deadline()
+		require.True(t, ok)
+		return d, nil
+	}))
+	got, err := l.Get(ctx)
+	require.NoError(t, err)
+	require.Equal(t, deadline, got)
+}
+
+func TestMemoize_Panic(t *testing.T) {
+	boom := errors.New("boom")
+	l := Memoize(NewLazy(func(ctx context.Context) (int, error) {
+		panic(boom)
+	}))
+	_, err := l.Get(context.Background())
+	require.ErrorIs(t, err, boom)
+	var pe *PanicError
+	require.ErrorAs(t, err, &pe)
+	require.Equal(t, boom, pe.Value)
+	require.Contains(t, string(pe.Stack), "memoize")
+
+	// Panic values which are not errors are kept as is
+	_, err = Memoize(NewLazy(func(ctx context.Context) (int, error) {
+		panic("oops")
+	})).Get(context.Background())
+	require.ErrorAs(t, err, &pe)
+	require.Equal(t, "oops", pe.Value)
+	require.EqualError(t, err, "shpanstream recovered error value: oops")
+}
@@ -19,6 +19,10 @@ type Lazy[T any] struct {
 	emptyValueErrSupplier func() error
 }
 
+// PanicError is the error a panic recovered on a background goroutine of a Lazy (e.g. a memoized fetch) is
+// returned as. It is the same type the stream package reports its recovered panics as.
+type PanicError = shpanstream.PanicError
+
 // NewLazyOptional creates a new Lazy, allow optional value
 func NewLazyOptional[T any](fetcher func(ctx context.Context) (*T, error)) Lazy[T] {
 	return newLazy[T](fetcher, defaultEmptyValueErr)
@@ -0,0 +1,35 @@
+package shpanstream
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// PanicError is the error a recovered panic is returned as, carrying the recovered value and the stack trace of the
+// panicking goroutine. Both the stream pipelines and the lazies report their recovered panics as a PanicError, so a
+// panic surfaces the same way whichever of them it was recovered in.
+// When the recovered value is an error, PanicError unwraps to it, so errors.Is and errors.As match it.
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+// NewPanicError creates a PanicError for the recovered value. It is meant to be called from the deferred recover, so
+// the stack captured is still the one of the panicking goroutine.
+func NewPanicError(value any) *PanicError {
+	return &PanicError{Value: value, Stack: debug.Stack()}
+}
+
+func (pe *PanicError) Error() string {
+	if asErr, ok := pe.Value.(error); ok {
+		return fmt.Sprintf("shpanstream recovered error: %v", asErr)
+	}
+	return fmt.Sprintf("shpanstream recovered error value: %v", pe.Value)
+}
+
+func (pe *PanicError) Unwrap() error {
+	if asErr, ok := pe.Value.(error); ok {
+		return asErr
+	}
+	return nil
+}
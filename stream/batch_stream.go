@@ -0,0 +1,104 @@
+package stream
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Batch groups the elements of the stream into batches, flushing a batch once it has maxSize elements, or once
+// maxWait elapsed since its first element arrived (whichever comes first). The source is read on a background
+// goroutine, so batches are flushed on time even when the source is slow. To keep reading the source while the
+// consumer is busy with a batch, use Buffered on the source.
+// The last partial batch is emitted when the stream ends, and when the context is cancelled or the source fails
+// (followed by the context or source error), so no element already read is lost.
+// For count based batches of a steady source, see Window.
+func Batch[T any](s Stream[T], maxSize int, maxWait time.Duration, opts ...TimeOption) Stream[[]T] {
+	if maxSize <= 0 {
+		return Error[[]T](fmt.Errorf("batch max size must be greater than 0"))
+	}
+	if maxWait <= 0 {
+		return Error[[]T](fmt.Errorf("batch max wait must be greater than 0"))
+	}
+	bp := &timedStreamProvider[T, []T]{
+		src:   &bufferedStreamProvider[T]{src: s, size: 1},
+		clock: newTimeConfig(opts).clock,
+	}
+	// An element that arrived after the previous batch was due, and opens the next batch
+	var carry *T
+	// A source error that arrived with a partial batch, returned once the batch was emitted
+	var pendingErr error
+	bp.openFunc = func() {
+		carry = nil
+		pendingErr = nil
+	}
+	bp.emitFunc = func(ctx context.Context) ([]T, error) {
+		if pendingErr != nil {
+			err := pendingErr
+			pendingErr = nil
+			return nil, err
+		}
+		var batch []T
+		var timer Timer
+		var timerChan <-chan time.Time
+		defer func() {
+			// Release the timer of a batch emitted before it was due
+			if timer != nil {
+				timer.Stop()
+			}
+		}()
+		startTimer := func() {
+			timer = bp.clock.NewTimer(maxWait)
+			timerChan = timer.C()
+		}
+		if carry != nil {
+			batch = append(batch, *carry)
+			carry = nil
+			startTimer()
+			if len(batch) >= maxSize {
+				return batch, nil
+			}
+		}
+		for {
+			select {
+			case <-ctx.Done():
+				if len(batch) > 0 {
+					return batch, nil
+				}
+				return nil, ctx.Err()
+			case r, stillGood := <-bp.src.bufferChan:
+				if !stillGood {
+					if len(batch) > 0 {
+						bp.srcDone = true
+						return batch, nil
+					}
+					return nil, bp.closedErr(ctx)
+				}
+				if r.Err != nil {
+					if len(batch) > 0 {
+						pendingErr = r.Err
+						return batch, nil
+					}
+					return nil, r.Err
+				}
+				select {
+				case <-timerChan:
+					// The batch was already due, the element belongs to the next one
+					carry = &r.Value
+					return batch, nil
+				default:
+				}
+				batch = append(batch, r.Value)
+				if len(batch) == 1 {
+					startTimer()
+				}
+				if len(batch) >= maxSize {
+					return batch, nil
+				}
+			case <-timerChan:
+				return batch, nil
+			}
+		}
+	}
+	return NewSimpleStream(bp.emit, WithOpenFuncOption(bp.open), WithCloseFuncOption(bp.close))
+}
@@ -0,0 +1,92 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestBatch_SizeFlush(t *testing.T) {
+	s := Batch(FromSlice(makeRange(10)), 4, time.Hour)
+	expected := [][]int{{0, 1, 2, 3}, {4, 5, 6, 7}, {8, 9}}
+	require.Equal(t, expected, s.MustCollect())
+	require.Equal(t, expected, s.MustCollect())
+}
+
+func TestBatch_TimeFlush(t *testing.T) {
+	clock := NewManualClock(clockTestStart)
+	ss, src := newSteppedSource()
+	batches := make(chan []int)
+	done := make(chan error, 1)
+	go func() {
+		done <- Batch(src, 3, time.Second, WithClockOption(clock)).Consume(context.Background(), func(b []int) {
+			batches <- b
+		})
+	}()
+
+	// The source is slow, the batch is flushed once maxWait elapsed since its first element
+	ss.push(1)
+	clock.BlockUntilWaiters(1)
+	clock.Advance(500 * time.Millisecond)
+	require.Equal(t, 1, clock.Waiters())
+	clock.Advance(500 * time.Millisecond)
+	require.Equal(t, []int{1}, <-batches)
+
+	// A full batch is flushed right away
+	ss.push(2)
+	ss.push(3)
+	ss.push(4)
+	require.Equal(t, []int{2, 3, 4}, <-batches)
+	// The timer of the batch flushed by size was released
+	require.Equal(t, 0, clock.Waiters())
+
+	// The last partial batch is flushed at the end
+	ss.push(5)
+	ss.end()
+	require.Equal(t, []int{5}, <-batches)
+	require.NoError(t, <-done)
+}
+
+func TestBatch_PartialBatchOnCancellation(t *testing.T) {
+	clock := NewManualClock(clockTestStart)
+	ss, src := newSteppedSource()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var batches [][]int
+	done := make(chan error, 1)
+	go func() {
+		done <- Batch(src, 10, time.Second, WithClockOption(clock)).Consume(ctx, func(b []int) {
+			batches = append(batches, b)
+		})
+	}()
+	ss.push(1)
+	// The timer is started once the first element was added to the batch
+	clock.BlockUntilWaiters(1)
+	cancel()
+
+	require.ErrorIs(t, <-done, context.Canceled)
+	require.Equal(t, [][]int{{1}}, batches)
+}
+
+func TestBatch_SourceError(t *testing.T) {
+	boom := errors.New("boom")
+	_, err := Batch(errAt(FromSlice(makeRange(10)), 5, boom), 2, time.Hour).Collect(context.Background())
+	require.ErrorIs(t, err, boom)
+
+	// The partial batch read before the error is emitted first, followed by the error
+	var batches [][]int
+	err = Batch(errAt(FromSlice(makeRange(10)), 5, boom), 2, time.Hour).Consume(context.Background(), func(b []int) {
+		batches = append(batches, b)
+	})
+	require.ErrorIs(t, err, boom)
+	require.Equal(t, [][]int{{0, 1}, {2, 3}, {4}}, batches)
+}
+
+func TestBatch_InvalidArguments(t *testing.T) {
+	_, err := Batch(Just(1), 0, time.Second).Collect(context.Background())
+	require.Error(t, err)
+	_, err = Batch(Just(1), 1, 0).Collect(context.Background())
+	require.Error(t, err)
+}
@@ -118,3 +118,12 @@ func (b *bufferedStreamProvider[T]) close() {
 		b.eofCancel()
 	}
 }
+
+// detach is like close, but does not wait for the buffering goroutine, for a source that might be stuck (e.g. a
+// provider ignoring the context). The goroutine still closes the source once its provider returns, and the
+// returned channel is closed once it did.
+func (b *bufferedStreamProvider[T]) detach() <-chan struct{} {
+	b.internalCancel()
+	b.eofCancel()
+	return b.bufferingDone
+}
@@ -0,0 +1,78 @@
+package stream
+
+import (
+	"context"
+	"github.com/shpandrak/shpanstream/internal/util"
+	"io"
+)
+
+// Cache records the elements of the stream during its first complete consumption, and replays them on later
+// consumptions without consuming the source again (e.g. an expensive sql query that is consumed more than once).
+// Only a complete consumption is cached: a consumption that fails or terminates early is not recorded, and the
+// next one consumes the source again. Elements are kept as is, so mutable elements (e.g. reused byte slices)
+// should be copied before caching.
+// The resulting stream is re-consumable sequentially, but (like all streams) must not be consumed concurrently.
+func (s Stream[T]) Cache() Stream[T] {
+	cp := &cacheStreamProvider[T]{src: s}
+	return NewSimpleStream(cp.emit, WithOpenFuncOption(cp.open), WithCloseFuncOption(cp.close))
+}
+
+type cacheStreamProvider[T any] struct {
+	src Stream[T]
+
+	// cached holds the elements once a consumption completed
+	cached *[]T
+
+	// Per-consumption state, (re)initialised in open and torn down in close.
+	replay    []T
+	recording []T
+	srcCancel context.CancelFunc
+}
+
+func (cp *cacheStreamProvider[T]) open(ctx context.Context) error {
+	if cp.cached != nil {
+		cp.replay = *cp.cached
+		return nil
+	}
+	srcCancel, err := doOpenStream[T](ctx, cp.src)
+	if err != nil {
+		return err
+	}
+	cp.srcCancel = srcCancel
+	cp.recording = nil
+	return nil
+}
+
+func (cp *cacheStreamProvider[T]) emit(ctx context.Context) (T, error) {
+	if cp.srcCancel == nil {
+		if ctx.Err() != nil {
+			return util.DefaultValue[T](), ctx.Err()
+		}
+		if len(cp.replay) == 0 {
+			return util.DefaultValue[T](), io.EOF
+		}
+		v := cp.replay[0]
+		cp.replay = cp.replay[1:]
+		return v, nil
+	}
+	v, err := cp.src.provider(ctx)
+	if err != nil {
+		if err == io.EOF {
+			recorded := cp.recording
+			cp.cached = &recorded
+		}
+		return util.DefaultValue[T](), err
+	}
+	cp.recording = append(cp.recording, v)
+	return v, nil
+}
+
+func (cp *cacheStreamProvider[T]) close() {
+	if cp.srcCancel != nil {
+		doCloseSubStream[T](cp.src)
+		cp.srcCancel()
+		cp.srcCancel = nil
+	}
+	cp.recording = nil
+	cp.replay = nil
+}
@@ -0,0 +1,58 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestStream_Cache(t *testing.T) {
+	var opened int
+	src := Just(1, 2, 3).WithAdditionalLifecycle(NewLifecycle(
+		func(ctx context.Context) error {
+			opened++
+			return nil
+		},
+		nil,
+	))
+	cached := src.Cache()
+	require.Equal(t, []int{1, 2, 3}, cached.MustCollect())
+	require.Equal(t, []int{1, 2, 3}, cached.MustCollect())
+	require.Equal(t, []int{1, 2}, cached.Limit(2).MustCollect())
+	require.Equal(t, 1, opened)
+}
+
+func TestStream_Cache_IncompleteConsumptionIsNotCached(t *testing.T) {
+	var opened int
+	src := Just(1, 2, 3).WithAdditionalLifecycle(NewLifecycle(
+		func(ctx context.Context) error {
+			opened++
+			return nil
+		},
+		nil,
+	))
+	cached := src.Cache()
+	require.Equal(t, []int{1}, cached.Limit(1).MustCollect())
+	require.Equal(t, []int{1, 2, 3}, cached.MustCollect())
+	require.Equal(t, []int{1, 2, 3}, cached.MustCollect())
+	require.Equal(t, 2, opened)
+}
+
+func TestStream_Cache_Error(t *testing.T) {
+	boom := errors.New("boom")
+	fail := true
+	cached := MapWithErr(Just(1, 2, 3), func(v int) (int, error) {
+		if v == 2 && fail {
+			return 0, boom
+		}
+		return v, nil
+	}).Cache()
+	_, err := cached.Collect(context.Background())
+	require.ErrorIs(t, err, boom)
+
+	fail = false
+	require.Equal(t, []int{1, 2, 3}, cached.MustCollect())
+	fail = true
+	require.Equal(t, []int{1, 2, 3}, cached.MustCollect())
+}
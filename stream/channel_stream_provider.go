@@ -2,6 +2,7 @@ package stream
 
 import (
 	"context"
+	"fmt"
 	"github.com/shpandrak/shpanstream/internal/util"
 	"io"
 	"log/slog"
@@ -36,3 +37,36 @@ func FromChannel[T any](ch <-chan T) Stream[T] {
 		originalChannel: ch,
 	})
 }
+
+// ToChannel consumes the stream on a background goroutine, sending its elements to the returned channel (buffered
+// with bufSize), for handing the stream to channel based code. The elements channel is closed once the stream
+// terminates, and then the error channel gets the error the stream failed with (if any) and is closed.
+// The caller must either drain the elements channel or cancel ctx, otherwise the goroutine (and the stream) is
+// blocked forever. A negative bufSize fails the stream right away.
+func (s Stream[T]) ToChannel(ctx context.Context, bufSize int) (<-chan T, <-chan error) {
+	errCh := make(chan error, 1)
+	if bufSize < 0 {
+		ch := make(chan T)
+		close(ch)
+		errCh <- fmt.Errorf("to channel buffer size must not be negative")
+		close(errCh)
+		return ch, errCh
+	}
+	ch := make(chan T, bufSize)
+	go func() {
+		defer close(errCh)
+		err := s.ConsumeWithErr(ctx, func(v T) error {
+			select {
+			case ch <- v:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		close(ch)
+		if err != nil {
+			errCh <- err
+		}
+	}()
+	return ch, errCh
+}
@@ -1,7 +1,11 @@
 package stream
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"github.com/stretchr/testify/require"
+	"testing"
 )
 
 func ExampleFromChannel() {
@@ -62,3 +66,65 @@ func ExampleFromChannel() {
 	).MustCollect())
 
 }
+
+func TestStream_ToChannel(t *testing.T) {
+	s := Just(1, 2, 3)
+	for range 2 {
+		ch, errCh := s.ToChannel(context.Background(), 1)
+		var res []int
+		for v := range ch {
+			res = append(res, v)
+		}
+		require.Equal(t, []int{1, 2, 3}, res)
+		require.NoError(t, <-errCh)
+	}
+
+	// Round trip back to a stream
+	ch, errCh := FromSlice(makeRange(10)).ToChannel(context.Background(), 0)
+	require.Equal(t, makeRange(10), FromChannel(ch).MustCollect())
+	require.NoError(t, <-errCh)
+}
+
+func TestStream_ToChannel_Error(t *testing.T) {
+	boom := errors.New("boom")
+	ch, errCh := errAt(FromSlice(makeRange(10)), 5, boom).ToChannel(context.Background(), 0)
+	var res []int
+	for v := range ch {
+		res = append(res, v)
+	}
+	require.Equal(t, []int{0, 1, 2, 3, 4}, res)
+	require.ErrorIs(t, <-errCh, boom)
+	_, stillGood := <-errCh
+	require.False(t, stillGood)
+}
+
+func TestStream_ToChannel_NegativeBufferSize(t *testing.T) {
+	ch, errCh := Just(1, 2).ToChannel(context.Background(), -1)
+	_, stillGood := <-ch
+	require.False(t, stillGood)
+	require.Error(t, <-errCh)
+	_, stillGood = <-errCh
+	require.False(t, stillGood)
+}
+
+func TestStream_ToChannel_ContextCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	closed := make(chan struct{})
+	infinite := NewSimpleStream(
+		func(ctx context.Context) (int, error) {
+			return 1, nil
+		},
+		WithCloseFuncOption(func() {
+			close(closed)
+		}),
+	)
+	ch, errCh := infinite.ToChannel(ctx, 0)
+	require.Equal(t, 1, <-ch)
+
+	// Stop receiving, cancelling releases the goroutine and closes the stream
+	cancel()
+	for range ch {
+	}
+	require.ErrorIs(t, <-errCh, context.Canceled)
+	<-closed
+}
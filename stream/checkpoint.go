@@ -0,0 +1,219 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/shpandrak/shpanstream/internal/util"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+// DefaultCheckpointEvery is the default number of consumed elements between saved checkpoints.
+const DefaultCheckpointEvery = 100
+
+// CheckpointStore persists the offset of the last consumed element of a long-running consumption, so the
+// consumption can resume after it (see Checkpoint and Resume).
+type CheckpointStore[O any] interface {
+	// Load returns the last saved offset, or nil when no offset was saved yet.
+	Load(ctx context.Context) (*O, error)
+	Save(ctx context.Context, offset O) error
+}
+
+// NewFileCheckpointStore creates a CheckpointStore that keeps the offset json encoded in a file. The file is
+// replaced atomically on each save, so a crash while saving keeps the previous offset.
+func NewFileCheckpointStore[O any](filePath string) CheckpointStore[O] {
+	return &fileCheckpointStore[O]{filePath: filePath, codec: JsonCodec[O]()}
+}
+
+type fileCheckpointStore[O any] struct {
+	filePath string
+	codec    Codec[O]
+}
+
+func (fs *fileCheckpointStore[O]) Load(_ context.Context) (*O, error) {
+	f, err := os.Open(fs.filePath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to open checkpoint file %s: %w", fs.filePath, err)
+	}
+	defer f.Close()
+	offset, err := fs.codec.NewDecoder(f).Decode()
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode checkpoint file %s: %w", fs.filePath, err)
+	}
+	return &offset, nil
+}
+
+func (fs *fileCheckpointStore[O]) Save(_ context.Context, offset O) (retErr error) {
+	f, err := os.CreateTemp(filepath.Dir(fs.filePath), filepath.Base(fs.filePath)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create checkpoint file: %w", err)
+	}
+	defer func() {
+		if retErr != nil {
+			_ = f.Close()
+			_ = os.Remove(f.Name())
+		}
+	}()
+	if err := fs.codec.NewEncoder(f).Encode(offset); err != nil {
+		return fmt.Errorf("failed to encode checkpoint: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("failed to sync checkpoint file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to close checkpoint file: %w", err)
+	}
+	if err := os.Rename(f.Name(), fs.filePath); err != nil {
+		return fmt.Errorf("failed to replace checkpoint file %s: %w", fs.filePath, err)
+	}
+	return nil
+}
+
+type CheckpointOption func(*checkpointConfig)
+
+type checkpointConfig struct {
+	every int
+}
+
+// WithCheckpointEveryOption sets the number of consumed elements between saved checkpoints,
+// default is DefaultCheckpointEvery.
+func WithCheckpointEveryOption(every int) CheckpointOption {
+	return func(cfg *checkpointConfig) {
+		cfg.every = every
+	}
+}
+
+// Checkpoint saves the offset (derived from the elements using offsetFunc) of the last consumed element to the
+// store, every number of elements (see WithCheckpointEveryOption) and when the stream terminates, so a crashed
+// consumption can be resumed using Resume.
+// An element is considered consumed once the next element is requested (or the stream completed), so Checkpoint
+// should be applied last, right before the stream is consumed. Elements emitted but not consumed (e.g. when the
+// consumer fails on an element) are not checkpointed, so they are consumed again on resume.
+// This only holds for a sequential consumer pulling the elements one at a time: a stage reading ahead downstream
+// of Checkpoint (e.g. Buffered, a concurrent map or Batch), or a consumer using WithConcurrentConsumeOption,
+// requests elements before the previous ones were processed, so offsets of elements that were never processed
+// get saved, and a resume skips them. Apply such stages upstream of Checkpoint, and consume it sequentially.
+// A failure to save a periodic or final checkpoint fails the stream. A checkpoint saved on early termination
+// (e.g. cancellation) is best effort, since the stream is already closing.
+func Checkpoint[T any, O any](
+	s Stream[T],
+	store CheckpointStore[O],
+	offsetFunc func(T) O,
+	opts ...CheckpointOption,
+) Stream[T] {
+	cfg := checkpointConfig{
+		every: DefaultCheckpointEvery,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.every <= 0 {
+		return Error[T](fmt.Errorf("checkpoint every must be greater than 0"))
+	}
+
+	// emitted is the offset of the last emitted element, and consumed the offset of the last consumed one
+	var emitted, consumed *O
+	var unsaved int
+	save := func(ctx context.Context) error {
+		if unsaved == 0 {
+			return nil
+		}
+		if err := store.Save(ctx, *consumed); err != nil {
+			return fmt.Errorf("failed to save checkpoint: %w", err)
+		}
+		unsaved = 0
+		return nil
+	}
+	return NewDownStreamSimple(
+		s,
+		func(ctx context.Context, srcProviderFunc ProviderFunc[T]) (T, error) {
+			// The previous element was consumed, since the next one is requested
+			if emitted != nil {
+				consumed = emitted
+				emitted = nil
+				unsaved++
+				if unsaved >= cfg.every {
+					if err := save(ctx); err != nil {
+						return util.DefaultValue[T](), err
+					}
+				}
+			}
+			v, err := srcProviderFunc(ctx)
+			if err != nil {
+				if err == io.EOF {
+					if saveErr := save(ctx); saveErr != nil {
+						return util.DefaultValue[T](), saveErr
+					}
+				}
+				return util.DefaultValue[T](), err
+			}
+			offset := offsetFunc(v)
+			emitted = &offset
+			return v, nil
+		},
+		func(_ context.Context, _ ProviderFunc[T]) error {
+			// Reset the state to support re-consumption (double collection)
+			emitted = nil
+			consumed = nil
+			unsaved = 0
+			return nil
+		},
+		func() {
+			// Early termination, saving what was consumed so far
+			if err := save(context.Background()); err != nil {
+				slog.Warn(fmt.Sprintf("error saving checkpoint on close: %v", err))
+			}
+		},
+	)
+}
+
+// Resume creates a stream that resumes a consumption from the offset saved in the store (see Checkpoint).
+// The offset is loaded when the stream is opened, and sourceFactory creates the source stream starting right
+// after it, or from the beginning when startOffset is nil (e.g. sql.StreamSqlQueryAfterKey or
+// file.StreamFromFileAtOffset).
+func Resume[T any, O any](store CheckpointStore[O], sourceFactory func(startOffset *O) Stream[T]) Stream[T] {
+	rp := &resumeStreamProvider[T, O]{store: store, sourceFactory: sourceFactory}
+	return NewSimpleStream(rp.emit, WithOpenFuncOption(rp.open), WithCloseFuncOption(rp.close))
+}
+
+type resumeStreamProvider[T any, O any] struct {
+	store         CheckpointStore[O]
+	sourceFactory func(startOffset *O) Stream[T]
+
+	// Per-consumption state, (re)initialised in open and torn down in close.
+	src       *Stream[T]
+	srcCancel context.CancelFunc
+}
+
+func (rp *resumeStreamProvider[T, O]) open(ctx context.Context) error {
+	startOffset, err := rp.store.Load(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load checkpoint: %w", err)
+	}
+	src := rp.sourceFactory(startOffset)
+	srcCancel, err := doOpenStream[T](ctx, src)
+	if err != nil {
+		return err
+	}
+	rp.src = &src
+	rp.srcCancel = srcCancel
+	return nil
+}
+
+func (rp *resumeStreamProvider[T, O]) emit(ctx context.Context) (T, error) {
+	return rp.src.provider(ctx)
+}
+
+func (rp *resumeStreamProvider[T, O]) close() {
+	if rp.src != nil {
+		doCloseSubStream[T](*rp.src)
+		rp.srcCancel()
+		rp.src = nil
+	}
+}
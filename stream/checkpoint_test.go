@@ -0,0 +1,139 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingCheckpointStore keeps all the saved offsets in memory.
+type recordingCheckpointStore struct {
+	mu    sync.Mutex
+	saved []int
+}
+
+func (rs *recordingCheckpointStore) Load(_ context.Context) (*int, error) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if len(rs.saved) == 0 {
+		return nil, nil
+	}
+	return &rs.saved[len(rs.saved)-1], nil
+}
+
+func (rs *recordingCheckpointStore) Save(_ context.Context, offset int) error {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.saved = append(rs.saved, offset)
+	return nil
+}
+
+func identityOffset(v int) int {
+	return v
+}
+
+func TestCheckpoint_Periodic(t *testing.T) {
+	store := &recordingCheckpointStore{}
+	s := Checkpoint(Just(1, 2, 3, 4, 5, 6, 7, 8, 9, 10), store, identityOffset, WithCheckpointEveryOption(3))
+	require.Equal(t, []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, s.MustCollect())
+	require.Equal(t, []int{3, 6, 9, 10}, store.saved)
+}
+
+func TestCheckpoint_FailedElementIsNotCheckpointed(t *testing.T) {
+	store := &recordingCheckpointStore{}
+	boom := errors.New("boom")
+	err := Checkpoint(Just(1, 2, 3, 4, 5), store, identityOffset).ConsumeWithErr(
+		context.Background(),
+		func(v int) error {
+			if v == 4 {
+				return boom
+			}
+			return nil
+		},
+	)
+	require.ErrorIs(t, err, boom)
+	require.Equal(t, []int{3}, store.saved)
+}
+
+func TestCheckpoint_EarlyTermination(t *testing.T) {
+	store := &recordingCheckpointStore{}
+	require.Equal(t, []int{1, 2}, Checkpoint(Just(1, 2, 3, 4, 5), store, identityOffset).Limit(2).MustCollect())
+	// The second element was emitted, but never confirmed as consumed
+	require.Equal(t, []int{1}, store.saved)
+}
+
+func TestCheckpoint_BufferedDownstreamSavesUnprocessedOffsets(t *testing.T) {
+	store := &recordingCheckpointStore{}
+	boom := errors.New("boom")
+	// Buffered reads ahead of the consumer, so Checkpoint takes the elements read into the buffer as consumed
+	err := Buffered(Checkpoint(FromSlice(makeRange(100)), store, identityOffset, WithCheckpointEveryOption(10)), 50).
+		ConsumeWithErr(context.Background(), func(v int) error {
+			if v == 0 {
+				require.Eventually(t, func() bool {
+					offset, _ := store.Load(context.Background())
+					return offset != nil
+				}, time.Second, time.Millisecond)
+				return boom
+			}
+			return nil
+		})
+	require.ErrorIs(t, err, boom)
+	// No element was processed, yet offsets were saved, so a resume would skip the elements up to them
+	offset, err := store.Load(context.Background())
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, *offset, 9)
+}
+
+func TestCheckpoint_ResumeWithFileStore(t *testing.T) {
+	store := NewFileCheckpointStore[int](filepath.Join(t.TempDir(), "job.checkpoint"))
+	offset, err := store.Load(context.Background())
+	require.NoError(t, err)
+	require.Nil(t, offset)
+
+	job := Checkpoint(
+		Resume(store, func(startOffset *int) Stream[int] {
+			src := FromSlice(makeRange(10))
+			if startOffset == nil {
+				return src
+			}
+			return src.Filter(func(v int) bool {
+				return v > *startOffset
+			})
+		}),
+		store,
+		identityOffset,
+		WithCheckpointEveryOption(2),
+	)
+
+	// The job crashes while processing 6
+	boom := errors.New("boom")
+	var processed []int
+	err = job.ConsumeWithErr(context.Background(), func(v int) error {
+		if v == 6 {
+			return boom
+		}
+		processed = append(processed, v)
+		return nil
+	})
+	require.ErrorIs(t, err, boom)
+	require.Equal(t, []int{0, 1, 2, 3, 4, 5}, processed)
+
+	// And resumes right where it stopped
+	require.Equal(t, []int{6, 7, 8, 9}, job.MustCollect())
+	offset, err = store.Load(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 9, *offset)
+
+	// Nothing left to process
+	require.Empty(t, job.MustCollect())
+}
+
+func TestCheckpoint_InvalidArguments(t *testing.T) {
+	_, err := Checkpoint(Just(1), &recordingCheckpointStore{}, identityOffset, WithCheckpointEveryOption(0)).
+		Collect(context.Background())
+	require.Error(t, err)
+}
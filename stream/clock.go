@@ -0,0 +1,149 @@
+package stream
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// Clock abstracts the passing of time for the time based operators (e.g. RateLimit, Debounce), allowing
+// deterministic tests using ManualClock.
+type Clock interface {
+	Now() time.Time
+
+	// NewTimer creates a timer that sends the current time on its channel once the duration elapsed. The timer
+	// can be stopped, releasing it before it fires.
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer is a single-shot timer created by Clock.NewTimer.
+type Timer interface {
+	// C returns the channel the current time is sent on, once the timer fires.
+	C() <-chan time.Time
+
+	// Stop prevents the timer from firing, returning false if it already fired or was stopped.
+	Stop() bool
+}
+
+// SystemClock returns the Clock backed by the time package.
+func SystemClock() Clock {
+	return systemClock{}
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) NewTimer(d time.Duration) Timer {
+	return systemTimer{time.NewTimer(d)}
+}
+
+type systemTimer struct {
+	t *time.Timer
+}
+
+func (st systemTimer) C() <-chan time.Time {
+	return st.t.C
+}
+
+func (st systemTimer) Stop() bool {
+	return st.t.Stop()
+}
+
+// ManualClock is a Clock that only moves when advanced, for deterministic tests of time based operators.
+type ManualClock struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	now     time.Time
+	waiters []manualClockWaiter
+}
+
+type manualClockWaiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+// NewManualClock creates a ManualClock set to the given time.
+func NewManualClock(now time.Time) *ManualClock {
+	c := &ManualClock{now: now}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+func (c *ManualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *ManualClock) NewTimer(d time.Duration) Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+	} else {
+		c.waiters = append(c.waiters, manualClockWaiter{at: c.now.Add(d), ch: ch})
+		c.cond.Broadcast()
+	}
+	return &manualClockTimer{clock: c, ch: ch}
+}
+
+type manualClockTimer struct {
+	clock *ManualClock
+	ch    chan time.Time
+}
+
+func (mt *manualClockTimer) C() <-chan time.Time {
+	return mt.ch
+}
+
+// Stop removes the timer from the pending waiters.
+func (mt *manualClockTimer) Stop() bool {
+	c := mt.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, w := range c.waiters {
+		if w.ch == mt.ch {
+			c.waiters = slices.Delete(c.waiters, i, i+1)
+			return true
+		}
+	}
+	return false
+}
+
+// Advance moves the clock forward, firing the waiters that are due.
+func (c *ManualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	remaining := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.at.After(c.now) {
+			remaining = append(remaining, w)
+		} else {
+			w.ch <- c.now
+		}
+	}
+	clear(c.waiters[len(remaining):])
+	c.waiters = remaining
+}
+
+// Waiters returns the number of pending timers, that were neither fired nor stopped yet.
+func (c *ManualClock) Waiters() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.waiters)
+}
+
+// BlockUntilWaiters blocks until there are at least n pending timers, allowing tests to advance the clock
+// only once the operator under test is waiting on it.
+func (c *ManualClock) BlockUntilWaiters(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.waiters) < n {
+		c.cond.Wait()
+	}
+}
@@ -0,0 +1,100 @@
+package stream
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"io"
+)
+
+// Encoder writes elements one after the other to an underlying writer.
+type Encoder[T any] interface {
+	Encode(v T) error
+}
+
+// Decoder reads elements one after the other from an underlying reader.
+// Decode should return io.EOF when there are no more elements.
+type Decoder[T any] interface {
+	Decode() (T, error)
+}
+
+// Codec creates encoders and decoders for streaming elements of type T to and from bytes.
+type Codec[T any] interface {
+	NewEncoder(w io.Writer) Encoder[T]
+	NewDecoder(r io.Reader) Decoder[T]
+}
+
+// EncoderFunc allows using a simple function as an Encoder.
+type EncoderFunc[T any] func(v T) error
+
+func (f EncoderFunc[T]) Encode(v T) error {
+	return f(v)
+}
+
+// DecoderFunc allows using a simple function as a Decoder.
+type DecoderFunc[T any] func() (T, error)
+
+func (f DecoderFunc[T]) Decode() (T, error) {
+	return f()
+}
+
+// NewCodec creates a Codec from encoder and decoder factory functions.
+func NewCodec[T any](
+	encoderFactory func(w io.Writer) Encoder[T],
+	decoderFactory func(r io.Reader) Decoder[T],
+) Codec[T] {
+	return funcCodec[T]{encoderFactory: encoderFactory, decoderFactory: decoderFactory}
+}
+
+type funcCodec[T any] struct {
+	encoderFactory func(w io.Writer) Encoder[T]
+	decoderFactory func(r io.Reader) Decoder[T]
+}
+
+func (fc funcCodec[T]) NewEncoder(w io.Writer) Encoder[T] {
+	return fc.encoderFactory(w)
+}
+
+func (fc funcCodec[T]) NewDecoder(r io.Reader) Decoder[T] {
+	return fc.decoderFactory(r)
+}
+
+// JsonCodec encodes elements as a sequence of json values (newline delimited json).
+func JsonCodec[T any]() Codec[T] {
+	return NewCodec(
+		func(w io.Writer) Encoder[T] {
+			enc := json.NewEncoder(w)
+			return EncoderFunc[T](func(v T) error {
+				return enc.Encode(v)
+			})
+		},
+		func(r io.Reader) Decoder[T] {
+			dec := json.NewDecoder(r)
+			return DecoderFunc[T](func() (T, error) {
+				var v T
+				err := dec.Decode(&v)
+				return v, err
+			})
+		},
+	)
+}
+
+// GobCodec encodes elements using encoding/gob. Note that gob only encodes exported fields, and that
+// concrete types stored in interface values must be registered using gob.Register.
+func GobCodec[T any]() Codec[T] {
+	return NewCodec(
+		func(w io.Writer) Encoder[T] {
+			enc := gob.NewEncoder(w)
+			return EncoderFunc[T](func(v T) error {
+				return enc.Encode(v)
+			})
+		},
+		func(r io.Reader) Decoder[T] {
+			dec := gob.NewDecoder(r)
+			return DecoderFunc[T](func() (T, error) {
+				var v T
+				err := dec.Decode(&v)
+				return v, err
+			})
+		},
+	)
+}
@@ -0,0 +1,236 @@
+package stream
+
+import (
+	"context"
+	"github.com/shpandrak/shpanstream"
+	"github.com/shpandrak/shpanstream/internal/util"
+	"slices"
+	"strings"
+)
+
+// Collector is a reduction of a stream into a result R, composed of a supplier of a fresh accumulation, an
+// accumulator folding each element into it, and a finisher turning the accumulation into the result.
+// Collectors compose, e.g. GroupingBy with a downstream Summarizing, so a report can be computed in a single pass.
+// Use CollectWith to run a collector on a stream, and NewCollector to create a custom one.
+type Collector[T any, R any] struct {
+	newAccumulation func() collectorAccumulation[T, R]
+}
+
+type collectorAccumulation[T any, R any] struct {
+	accumulate func(v T)
+	finish     func() R
+}
+
+// NewCollector creates a collector from a supplier of a fresh accumulation (called once per collection), an
+// accumulator folding an element into the accumulation (returning the updated one, so value types can be used),
+// and a finisher turning the accumulation into the result.
+func NewCollector[T any, A any, R any](
+	supplier func() A,
+	accumulator func(acc A, v T) A,
+	finisher func(acc A) R,
+) Collector[T, R] {
+	return Collector[T, R]{
+		newAccumulation: func() collectorAccumulation[T, R] {
+			acc := supplier()
+			return collectorAccumulation[T, R]{
+				accumulate: func(v T) {
+					acc = accumulator(acc, v)
+				},
+				finish: func() R {
+					return finisher(acc)
+				},
+			}
+		},
+	}
+}
+
+// CollectWith consumes the entire stream, collecting its elements using the collector.
+func CollectWith[T any, R any](ctx context.Context, s Stream[T], collector Collector[T, R]) (R, error) {
+	acc := collector.newAccumulation()
+	err := s.Consume(ctx, acc.accumulate)
+	if err != nil {
+		return util.DefaultValue[R](), err
+	}
+	return acc.finish(), nil
+}
+
+// ToSlice collects the elements into a slice, in stream order.
+func ToSlice[T any]() Collector[T, []T] {
+	return NewCollector(
+		func() []T {
+			return nil
+		},
+		func(acc []T, v T) []T {
+			return append(acc, v)
+		},
+		func(acc []T) []T {
+			return acc
+		},
+	)
+}
+
+// ToSortedSlice collects the elements into a slice, sorted using the comparator. Equal elements keep their
+// stream order. For the first k sorted elements only, see SortedLimit.
+func ToSortedSlice[T any](comparator shpanstream.Comparator[T]) Collector[T, []T] {
+	return NewCollector(
+		func() []T {
+			return nil
+		},
+		func(acc []T, v T) []T {
+			return append(acc, v)
+		},
+		func(acc []T) []T {
+			slices.SortStableFunc(acc, comparator)
+			return acc
+		},
+	)
+}
+
+// Counting counts the elements.
+func Counting[T any]() Collector[T, uint64] {
+	return NewCollector(
+		func() uint64 {
+			return 0
+		},
+		func(acc uint64, _ T) uint64 {
+			return acc + 1
+		},
+		func(acc uint64) uint64 {
+			return acc
+		},
+	)
+}
+
+// Mapping maps the elements before collecting them using the downstream collector, e.g. collecting a single
+// field of the elements of each group in GroupingBy.
+func Mapping[T any, U any, R any](mapper shpanstream.Mapper[T, U], downstream Collector[U, R]) Collector[T, R] {
+	return Collector[T, R]{
+		newAccumulation: func() collectorAccumulation[T, R] {
+			acc := downstream.newAccumulation()
+			return collectorAccumulation[T, R]{
+				accumulate: func(v T) {
+					acc.accumulate(mapper(v))
+				},
+				finish: acc.finish,
+			}
+		},
+	}
+}
+
+// GroupingBy classifies the elements by key, collecting the elements of each group using the downstream
+// collector (e.g. ToSlice, Counting or Summarizing).
+func GroupingBy[T any, K comparable, R any](
+	keyFunc shpanstream.Mapper[T, K],
+	downstream Collector[T, R],
+) Collector[T, map[K]R] {
+	return Collector[T, map[K]R]{
+		newAccumulation: func() collectorAccumulation[T, map[K]R] {
+			groups := make(map[K]collectorAccumulation[T, R])
+			return collectorAccumulation[T, map[K]R]{
+				accumulate: func(v T) {
+					k := keyFunc(v)
+					acc, ok := groups[k]
+					if !ok {
+						acc = downstream.newAccumulation()
+						groups[k] = acc
+					}
+					acc.accumulate(v)
+				},
+				finish: func() map[K]R {
+					ret := make(map[K]R, len(groups))
+					for k, acc := range groups {
+						ret[k] = acc.finish()
+					}
+					return ret
+				},
+			}
+		},
+	}
+}
+
+// PartitioningBy splits the elements by the predicate, collecting the elements of each partition using the
+// downstream collector. The result always has both the true and false partitions, even if empty.
+func PartitioningBy[T any, R any](
+	predicate shpanstream.Predicate[T],
+	downstream Collector[T, R],
+) Collector[T, map[bool]R] {
+	return Collector[T, map[bool]R]{
+		newAccumulation: func() collectorAccumulation[T, map[bool]R] {
+			matching := downstream.newAccumulation()
+			notMatching := downstream.newAccumulation()
+			return collectorAccumulation[T, map[bool]R]{
+				accumulate: func(v T) {
+					if predicate(v) {
+						matching.accumulate(v)
+					} else {
+						notMatching.accumulate(v)
+					}
+				},
+				finish: func() map[bool]R {
+					return map[bool]R{
+						true:  matching.finish(),
+						false: notMatching.finish(),
+					}
+				},
+			}
+		},
+	}
+}
+
+// Joining concatenates the elements, separated by sep.
+func Joining(sep string) Collector[string, string] {
+	return NewCollector(
+		func() []string {
+			return nil
+		},
+		func(acc []string, v string) []string {
+			return append(acc, v)
+		},
+		func(acc []string) string {
+			return strings.Join(acc, sep)
+		},
+	)
+}
+
+// Summary is the result of the Summarizing collector. Min, Max and Avg are zero when Count is zero.
+// Sum is accumulated in N, so it is exact for integer types like Min and Max are, and wraps around like any N
+// arithmetic when exceeding the range of N; map small types to a wider one (e.g. int8 to int64) to summarize them.
+type Summary[N shpanstream.Number] struct {
+	Count uint64
+	Sum   N
+	Min   N
+	Max   N
+}
+
+// Avg returns the average of the summarized values, or 0 when there were none.
+func (s Summary[N]) Avg() float64 {
+	if s.Count == 0 {
+		return 0
+	}
+	return float64(s.Sum) / float64(s.Count)
+}
+
+// Summarizing summarizes (count, sum, min, max and average) the numeric values mapped from the elements.
+func Summarizing[T any, N shpanstream.Number](mapper shpanstream.Mapper[T, N]) Collector[T, Summary[N]] {
+	return NewCollector(
+		func() Summary[N] {
+			return Summary[N]{}
+		},
+		func(acc Summary[N], v T) Summary[N] {
+			n := mapper(v)
+			if acc.Count == 0 {
+				acc.Min = n
+				acc.Max = n
+			} else {
+				acc.Min = min(acc.Min, n)
+				acc.Max = max(acc.Max, n)
+			}
+			acc.Count++
+			acc.Sum += n
+			return acc
+		},
+		func(acc Summary[N]) Summary[N] {
+			return acc
+		},
+	)
+}
@@ -0,0 +1,126 @@
+package stream
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+type collectorTestSale struct {
+	region string
+	item   string
+	amount int
+}
+
+var collectorTestSales = []collectorTestSale{
+	{"eu", "apple", 3},
+	{"us", "pear", 5},
+	{"eu", "pear", 1},
+	{"us", "apple", 7},
+	{"eu", "plum", 2},
+}
+
+func TestCollectWith_GroupingBy(t *testing.T) {
+	ctx := context.Background()
+	byRegion := func(s collectorTestSale) string {
+		return s.region
+	}
+
+	counts, err := CollectWith(ctx, FromSlice(collectorTestSales), GroupingBy(byRegion, Counting[collectorTestSale]()))
+	require.NoError(t, err)
+	require.Equal(t, map[string]uint64{"eu": 3, "us": 2}, counts)
+
+	items, err := CollectWith(
+		ctx,
+		FromSlice(collectorTestSales),
+		GroupingBy(byRegion, Mapping(func(s collectorTestSale) string { return s.item }, Joining(","))),
+	)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"eu": "apple,pear,plum", "us": "pear,apple"}, items)
+
+	// Nested grouping
+	nested, err := CollectWith(
+		ctx,
+		FromSlice(collectorTestSales),
+		GroupingBy(byRegion, GroupingBy(func(s collectorTestSale) bool { return s.amount > 2 }, Counting[collectorTestSale]())),
+	)
+	require.NoError(t, err)
+	require.Equal(t, map[string]map[bool]uint64{"eu": {true: 1, false: 2}, "us": {true: 2}}, nested)
+}
+
+func TestCollectWith_Summarizing(t *testing.T) {
+	summaries, err := CollectWith(
+		context.Background(),
+		FromSlice(collectorTestSales),
+		GroupingBy(
+			func(s collectorTestSale) string { return s.region },
+			Summarizing(func(s collectorTestSale) int { return s.amount }),
+		),
+	)
+	require.NoError(t, err)
+	require.Equal(t, Summary[int]{Count: 3, Sum: 6, Min: 1, Max: 3}, summaries["eu"])
+	require.Equal(t, 2.0, summaries["eu"].Avg())
+	require.Equal(t, Summary[int]{Count: 2, Sum: 12, Min: 5, Max: 7}, summaries["us"])
+
+	empty, err := CollectWith(context.Background(), Empty[float64](), Summarizing(func(v float64) float64 { return v }))
+	require.NoError(t, err)
+	require.Equal(t, Summary[float64]{}, empty)
+	require.Equal(t, 0.0, empty.Avg())
+
+	// Small types are summarized by mapping them to a wider type
+	small, err := CollectWith(context.Background(), Just[int8](100, 100, 127), Summarizing(func(v int8) int64 { return int64(v) }))
+	require.NoError(t, err)
+	require.Equal(t, Summary[int64]{Count: 3, Sum: 327, Min: 100, Max: 127}, small)
+	require.Equal(t, 109.0, small.Avg())
+
+	// Integer sums stay exact beyond the float64 precision (2^53), like Min and Max
+	large, err := CollectWith(context.Background(), Just[int64](1<<53, 1, 1), Summarizing(func(v int64) int64 { return v }))
+	require.NoError(t, err)
+	require.Equal(t, Summary[int64]{Count: 3, Sum: 1<<53 + 2, Min: 1, Max: 1 << 53}, large)
+}
+
+func TestCollectWith_PartitioningBy(t *testing.T) {
+	isEven := func(v int) bool {
+		return v%2 == 0
+	}
+	res, err := CollectWith(context.Background(), Just(1, 2, 3, 4, 5), PartitioningBy(isEven, ToSlice[int]()))
+	require.NoError(t, err)
+	require.Equal(t, map[bool][]int{true: {2, 4}, false: {1, 3, 5}}, res)
+
+	// Both partitions are always present
+	counts, err := CollectWith(context.Background(), Just(2, 4), PartitioningBy(isEven, Counting[int]()))
+	require.NoError(t, err)
+	require.Equal(t, map[bool]uint64{true: 2, false: 0}, counts)
+}
+
+func TestCollectWith_JoiningAndSorted(t *testing.T) {
+	ctx := context.Background()
+	joined, err := CollectWith(ctx, Just("a", "", "c"), Joining(", "))
+	require.NoError(t, err)
+	require.Equal(t, "a, , c", joined)
+
+	joined, err = CollectWith(ctx, Empty[string](), Joining(", "))
+	require.NoError(t, err)
+	require.Equal(t, "", joined)
+
+	sorted, err := CollectWith(ctx, Just(3, 1, 2), ToSortedSlice(cmp.Compare[int]))
+	require.NoError(t, err)
+	require.Equal(t, []int{1, 2, 3}, sorted)
+}
+
+func TestCollectWith_FreshAccumulationPerCollection(t *testing.T) {
+	collector := GroupingBy(func(v int) int { return v % 2 }, Counting[int]())
+	for range 2 {
+		res, err := CollectWith(context.Background(), Just(1, 2, 3), collector)
+		require.NoError(t, err)
+		require.Equal(t, map[int]uint64{0: 1, 1: 2}, res)
+	}
+}
+
+func TestCollectWith_Error(t *testing.T) {
+	boom := errors.New("boom")
+	_, err := CollectWith(context.Background(), errAt(FromSlice(makeRange(10)), 5, boom), Counting[int]())
+	require.ErrorIs(t, err, boom)
+}
@@ -2,6 +2,7 @@ package stream
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/shpandrak/shpanstream"
 	"github.com/shpandrak/shpanstream/internal/util"
@@ -97,8 +98,10 @@ func (c *concurrentStreamMapperProvider[SRC, TGT]) open(ctx context.Context) err
 							return
 						}
 					} else {
-						// Run the mapper function concurrently
-						tgt, err := c.mapper(internalCtx, entry.Value)
+						// Run the mapper function concurrently, a panic fails the stream as the mapper error
+						tgt, err := callWithPanicGuard(func() (TGT, error) {
+							return c.mapper(internalCtx, entry.Value)
+						})
 						if err != nil {
 							select {
 							case c.tgtChan <- shpanstream.Result[TGT]{Err: err}:
@@ -136,8 +139,10 @@ func (c *concurrentStreamMapperProvider[SRC, TGT]) open(ctx context.Context) err
 			case <-internalCtx.Done():
 				return
 			default:
-				// Read from the source stream
-				v, err := srcProviderFunc(internalCtx)
+				// Read from the source stream, a panic upstream fails the stream as the source error
+				v, err := callWithPanicGuard(func() (SRC, error) {
+					return srcProviderFunc(internalCtx)
+				})
 				if err != nil {
 					if err == io.EOF {
 						// Source done: signal EOF and deplete the buffer before the target channel
@@ -151,6 +156,11 @@ func (c *concurrentStreamMapperProvider[SRC, TGT]) open(ctx context.Context) err
 						case <-internalCtx.Done():
 							return
 						}
+						var panicErr *PanicError
+						if errors.As(err, &panicErr) {
+							// The source can't be pulled again after panicking
+							return
+						}
 					}
 				} else {
 					select {
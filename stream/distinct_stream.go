@@ -0,0 +1,322 @@
+package stream
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"github.com/shpandrak/shpanstream/internal/util"
+	"hash/maphash"
+	"math"
+	"reflect"
+)
+
+type DistinctOption func(*distinctConfig)
+
+type distinctConfig struct {
+	maxKeys           int
+	expectedElements  int
+	falsePositiveRate float64
+}
+
+// WithDistinctMaxKeysOption bounds the memory used by Distinct, by remembering only the last maxKeys distinct
+// keys. A duplicate is dropped only if its key is still remembered, so duplicates that are further apart than
+// maxKeys distinct elements are emitted again. Useful for feeds replaying recent elements (e.g. after reconnect).
+func WithDistinctMaxKeysOption(maxKeys int) DistinctOption {
+	return func(cfg *distinctConfig) {
+		cfg.maxKeys = maxKeys
+	}
+}
+
+// WithDistinctApproximateOption bounds the memory used by Distinct for unbounded streams, by using a bloom
+// filter sized for expectedElements with the given false positive rate instead of a set.
+// Duplicates are always dropped, since keys that are equal (as compared by ==) are hashed the same, e.g. 0 and -0
+// floats, or pointers by address. A false positive causes a distinct element to be dropped as well.
+// NaN keys, which never equal themselves, are all treated as the same key.
+// Once more than expectedElements distinct elements are seen, the false positive rate gradually grows.
+func WithDistinctApproximateOption(expectedElements int, falsePositiveRate float64) DistinctOption {
+	return func(cfg *distinctConfig) {
+		cfg.expectedElements = expectedElements
+		cfg.falsePositiveRate = falsePositiveRate
+	}
+}
+
+// Distinct drops elements that were already emitted, keeping the first occurrence of each element.
+// By default, all distinct elements are kept in memory, see WithDistinctMaxKeysOption and
+// WithDistinctApproximateOption for bounded-memory alternatives, or DistinctUntilChanged for sorted streams.
+func Distinct[T comparable](s Stream[T], opts ...DistinctOption) Stream[T] {
+	return DistinctBy(s, util.Identity[T](), opts...)
+}
+
+// DistinctBy drops elements whose key was already emitted, keeping the first element of each key, see Distinct.
+func DistinctBy[T any, K comparable](s Stream[T], keyFunc func(T) K, opts ...DistinctOption) Stream[T] {
+	cfg := distinctConfig{}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	var newKeySet func() distinctKeySet[K]
+	switch {
+	case cfg.maxKeys != 0 && cfg.expectedElements != 0:
+		return Error[T](fmt.Errorf("distinct max keys and approximate options are mutually exclusive"))
+	case cfg.maxKeys < 0:
+		return Error[T](fmt.Errorf("distinct max keys must be greater than 0"))
+	case cfg.maxKeys > 0:
+		newKeySet = func() distinctKeySet[K] {
+			return newBoundedDistinctKeySet[K](cfg.maxKeys)
+		}
+	case cfg.expectedElements != 0:
+		if cfg.expectedElements < 0 {
+			return Error[T](fmt.Errorf("distinct expected elements must be greater than 0"))
+		}
+		if cfg.falsePositiveRate <= 0 || cfg.falsePositiveRate >= 1 {
+			return Error[T](fmt.Errorf("distinct false positive rate must be between 0 and 1 (exclusive)"))
+		}
+		newKeySet = func() distinctKeySet[K] {
+			return newBloomDistinctKeySet[K](cfg.expectedElements, cfg.falsePositiveRate)
+		}
+	default:
+		newKeySet = func() distinctKeySet[K] {
+			return mapDistinctKeySet[K]{}
+		}
+	}
+
+	var seen distinctKeySet[K]
+	return NewDownStreamSimple(
+		s,
+		func(ctx context.Context, srcProviderFunc ProviderFunc[T]) (T, error) {
+			for {
+				v, err := srcProviderFunc(ctx)
+				if err != nil {
+					return util.DefaultValue[T](), err
+				}
+				if seen.add(keyFunc(v)) {
+					return v, nil
+				}
+			}
+		},
+		func(_ context.Context, _ ProviderFunc[T]) error {
+			// Reset the state to support re-consumption (double collection)
+			seen = newKeySet()
+			return nil
+		},
+		func() {
+			seen = nil
+		},
+	)
+}
+
+// DistinctUntilChanged drops elements that are equal to the previous element, using constant memory.
+// For sorted streams this is equivalent to Distinct.
+func DistinctUntilChanged[T comparable](s Stream[T]) Stream[T] {
+	return DistinctUntilChangedBy(s, util.Identity[T]())
+}
+
+// DistinctUntilChangedBy drops elements whose key is equal to the key of the previous element,
+// see DistinctUntilChanged.
+func DistinctUntilChangedBy[T any, K comparable](s Stream[T], keyFunc func(T) K) Stream[T] {
+	var lastKey K
+	started := false
+	return NewDownStreamSimple(
+		s,
+		func(ctx context.Context, srcProviderFunc ProviderFunc[T]) (T, error) {
+			for {
+				v, err := srcProviderFunc(ctx)
+				if err != nil {
+					return util.DefaultValue[T](), err
+				}
+				k := keyFunc(v)
+				if !started || k != lastKey {
+					started = true
+					lastKey = k
+					return v, nil
+				}
+			}
+		},
+		func(_ context.Context, _ ProviderFunc[T]) error {
+			// Reset the state to support re-consumption (double collection)
+			started = false
+			lastKey = util.DefaultValue[K]()
+			return nil
+		},
+		nil,
+	)
+}
+
+type distinctKeySet[K comparable] interface {
+	// add adds the key, returning false if it was already present
+	add(k K) bool
+}
+
+type mapDistinctKeySet[K comparable] map[K]struct{}
+
+func (m mapDistinctKeySet[K]) add(k K) bool {
+	if _, ok := m[k]; ok {
+		return false
+	}
+	m[k] = struct{}{}
+	return true
+}
+
+// boundedDistinctKeySet remembers the last maxKeys keys, evicting the oldest key first.
+type boundedDistinctKeySet[K comparable] struct {
+	keys map[K]struct{}
+	ring []K
+	next int
+}
+
+func newBoundedDistinctKeySet[K comparable](maxKeys int) *boundedDistinctKeySet[K] {
+	return &boundedDistinctKeySet[K]{
+		keys: make(map[K]struct{}, maxKeys),
+		ring: make([]K, 0, maxKeys),
+	}
+}
+
+func (b *boundedDistinctKeySet[K]) add(k K) bool {
+	if _, ok := b.keys[k]; ok {
+		return false
+	}
+	if len(b.ring) < cap(b.ring) {
+		b.ring = append(b.ring, k)
+	} else {
+		delete(b.keys, b.ring[b.next])
+		b.ring[b.next] = k
+		b.next = (b.next + 1) % len(b.ring)
+	}
+	b.keys[k] = struct{}{}
+	return true
+}
+
+// bloomDistinctKeySet is a bloom filter, using double hashing to derive the bit positions.
+type bloomDistinctKeySet[K comparable] struct {
+	bits      []uint64
+	numBits   uint64
+	numHashes int
+	seed      maphash.Seed
+	h         maphash.Hash
+}
+
+func newBloomDistinctKeySet[K comparable](expectedElements int, falsePositiveRate float64) *bloomDistinctKeySet[K] {
+	// Optimal sizes: m = -n*ln(p)/ln(2)^2, k = m/n*ln(2)
+	m := math.Ceil(-float64(expectedElements) * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2))
+	numBits := uint64(max(m, 64))
+	numHashes := int(max(math.Round(m/float64(expectedElements)*math.Ln2), 1))
+	ret := &bloomDistinctKeySet[K]{
+		bits:      make([]uint64, (numBits+63)/64),
+		numBits:   numBits,
+		numHashes: numHashes,
+		seed:      maphash.MakeSeed(),
+	}
+	ret.h.SetSeed(ret.seed)
+	return ret
+}
+
+func (b *bloomDistinctKeySet[K]) add(k K) bool {
+	h1, h2 := b.hash(k)
+	added := false
+	for i := 0; i < b.numHashes; i++ {
+		bit := (h1 + uint64(i)*h2) % b.numBits
+		word, mask := bit/64, uint64(1)<<(bit%64)
+		if b.bits[word]&mask == 0 {
+			b.bits[word] |= mask
+			added = true
+		}
+	}
+	return added
+}
+
+func (b *bloomDistinctKeySet[K]) hash(k K) (uint64, uint64) {
+	b.h.Reset()
+	writeDistinctKey(&b.h, k)
+	h1 := b.h.Sum64()
+	// Derive a second hash by feeding the first one back, making sure it is odd so probes never repeat
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], h1)
+	_, _ = b.h.Write(buf[:])
+	return h1, b.h.Sum64() | 1
+}
+
+// writeDistinctKey writes a binary encoding of the key to the hash, which is identical for keys that are equal
+// (as compared by ==). Common key types are written directly, other comparable types are written by
+// writeDistinctValue.
+func writeDistinctKey[K comparable](h *maphash.Hash, k K) {
+	var buf [8]byte
+	switch v := any(k).(type) {
+	case string:
+		_, _ = h.WriteString(v)
+	case int:
+		binary.LittleEndian.PutUint64(buf[:], uint64(v))
+		_, _ = h.Write(buf[:])
+	case int64:
+		binary.LittleEndian.PutUint64(buf[:], uint64(v))
+		_, _ = h.Write(buf[:])
+	case uint64:
+		binary.LittleEndian.PutUint64(buf[:], v)
+		_, _ = h.Write(buf[:])
+	default:
+		writeDistinctValue(h, reflect.ValueOf(v))
+	}
+}
+
+// writeDistinctValue writes the value by its kind, so equal values are written the same even when their textual
+// representation differs: floats are normalized (-0 is written as 0), pointers and channels are written by
+// address, and the fields of structs (other than blank ones, which == ignores) and arrays are written recursively.
+// NaN never equals itself, all NaNs are written the same.
+func writeDistinctValue(h *maphash.Hash, rv reflect.Value) {
+	var buf [8]byte
+	writeUint64 := func(u uint64) {
+		binary.LittleEndian.PutUint64(buf[:], u)
+		_, _ = h.Write(buf[:])
+	}
+	switch rv.Kind() {
+	case reflect.Invalid:
+		// A nil interface
+		_ = h.WriteByte(0)
+	case reflect.Bool:
+		if rv.Bool() {
+			writeUint64(1)
+		} else {
+			writeUint64(0)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		writeUint64(uint64(rv.Int()))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		writeUint64(rv.Uint())
+	case reflect.Float32, reflect.Float64:
+		writeUint64(distinctFloatBits(rv.Float()))
+	case reflect.Complex64, reflect.Complex128:
+		c := rv.Complex()
+		writeUint64(distinctFloatBits(real(c)))
+		writeUint64(distinctFloatBits(imag(c)))
+	case reflect.String:
+		// Length prefixed, so adjacent strings of a struct can't run into each other
+		writeUint64(uint64(rv.Len()))
+		_, _ = h.WriteString(rv.String())
+	case reflect.Pointer, reflect.Chan, reflect.UnsafePointer:
+		writeUint64(uint64(rv.Pointer()))
+	case reflect.Interface:
+		writeDistinctValue(h, rv.Elem())
+	case reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			writeDistinctValue(h, rv.Index(i))
+		}
+	case reflect.Struct:
+		for i := 0; i < rv.NumField(); i++ {
+			if rv.Type().Field(i).Name != "_" {
+				writeDistinctValue(h, rv.Field(i))
+			}
+		}
+	default:
+		// Same as using it as a map key
+		panic(fmt.Sprintf("distinct key of type %s is not hashable", rv.Type()))
+	}
+}
+
+func distinctFloatBits(f float64) uint64 {
+	switch {
+	case f == 0:
+		// Both 0 and -0
+		return 0
+	case math.IsNaN(f):
+		return math.Float64bits(math.NaN())
+	}
+	return math.Float64bits(f)
+}
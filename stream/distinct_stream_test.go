@@ -0,0 +1,133 @@
+package stream
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestDistinct(t *testing.T) {
+	s := Distinct(Just(1, 2, 1, 3, 2, 4, 1))
+	require.Equal(t, []int{1, 2, 3, 4}, s.MustCollect())
+
+	// Re-consumption starts with a fresh state
+	require.Equal(t, []int{1, 2, 3, 4}, s.MustCollect())
+
+	require.Empty(t, Distinct(Empty[int]()).MustCollect())
+}
+
+func TestDistinctBy(t *testing.T) {
+	require.Equal(
+		t,
+		[]string{"apple", "Banana", "cherry"},
+		DistinctBy(Just("apple", "Banana", "APPLE", "cherry", "banana"), strings.ToLower).MustCollect(),
+	)
+}
+
+func TestDistinct_MaxKeys(t *testing.T) {
+	// Only the last 2 keys are remembered, so 1 is emitted again once evicted
+	require.Equal(
+		t,
+		[]int{1, 2, 3, 1},
+		Distinct(Just(1, 2, 2, 1, 3, 3, 1), WithDistinctMaxKeysOption(2)).MustCollect(),
+	)
+}
+
+func TestDistinct_Approximate(t *testing.T) {
+	var src []string
+	for i := 0; i < 1000; i++ {
+		src = append(src, fmt.Sprintf("item-%d", i))
+	}
+	// Replay everything, as after a reconnect
+	src = append(src, src...)
+
+	res := Distinct(FromSlice(src), WithDistinctApproximateOption(1000, 0.01)).MustCollect()
+
+	// Duplicates are always dropped, and only a few distinct elements are expected to be false positives
+	require.LessOrEqual(t, len(res), 1000)
+	require.Greater(t, len(res), 950)
+	seen := map[string]bool{}
+	for _, v := range res {
+		require.False(t, seen[v])
+		seen[v] = true
+	}
+
+	type key struct {
+		a int
+		b string
+	}
+	require.Equal(
+		t,
+		[]key{{1, "a"}, {2, "b"}},
+		Distinct(Just(key{1, "a"}, key{2, "b"}, key{1, "a"}), WithDistinctApproximateOption(100, 0.001)).MustCollect(),
+	)
+}
+
+func TestDistinct_ApproximateHashesEqualKeysTheSame(t *testing.T) {
+	approximate := WithDistinctApproximateOption(100, 0.001)
+
+	type floatKey struct {
+		_ int
+		f float64
+		c complex128
+	}
+	negZero := math.Copysign(0, -1)
+	require.Len(t, Distinct(Just(floatKey{f: 0}, floatKey{f: negZero}), approximate).MustCollect(), 1)
+	require.Len(t, Distinct(Just(floatKey{c: complex(0, 0)}, floatKey{c: complex(negZero, negZero)}), approximate).MustCollect(), 1)
+
+	// Pointers are equal by address, even if the pointed value changed in between
+	type node struct{ name string }
+	n := &node{name: "a"}
+	res := DistinctBy(Just("a", "b", "c"), func(name string) *node {
+		n.name = name
+		return n
+	}, approximate).MustCollect()
+	require.Equal(t, []string{"a"}, res)
+
+	type id string
+	type arrayKey struct {
+		ids  [2]id
+		some any
+	}
+	require.Equal(
+		t,
+		[]arrayKey{{ids: [2]id{"a", "b"}, some: 1}, {ids: [2]id{"ab", ""}, some: 1}, {ids: [2]id{"a", "b"}}},
+		Distinct(Just(
+			arrayKey{ids: [2]id{"a", "b"}, some: 1},
+			arrayKey{ids: [2]id{"ab", ""}, some: 1},
+			arrayKey{ids: [2]id{"a", "b"}, some: 1},
+			arrayKey{ids: [2]id{"a", "b"}},
+		), approximate).MustCollect(),
+	)
+}
+
+func TestDistinct_InvalidOptions(t *testing.T) {
+	_, err := Distinct(Just(1), WithDistinctMaxKeysOption(-1)).Collect(context.Background())
+	require.Error(t, err)
+	_, err = Distinct(Just(1), WithDistinctApproximateOption(10, 1.5)).Collect(context.Background())
+	require.Error(t, err)
+	_, err = Distinct(Just(1), WithDistinctMaxKeysOption(10), WithDistinctApproximateOption(10, 0.1)).Collect(context.Background())
+	require.Error(t, err)
+}
+
+func TestDistinctUntilChanged(t *testing.T) {
+	s := DistinctUntilChanged(Just(1, 1, 2, 2, 2, 1, 3, 3))
+	require.Equal(t, []int{1, 2, 1, 3}, s.MustCollect())
+	require.Equal(t, []int{1, 2, 1, 3}, s.MustCollect())
+
+	// The zero value is not treated as already seen
+	require.Equal(t, []int{0, 1}, DistinctUntilChanged(Just(0, 0, 1)).MustCollect())
+}
+
+func TestDistinctUntilChangedBy(t *testing.T) {
+	require.Equal(
+		t,
+		[]string{"a1", "b1", "a3"},
+		DistinctUntilChangedBy(Just("a1", "a2", "b1", "b2", "a3"), func(v string) byte {
+			return v[0]
+		}).MustCollect(),
+	)
+}
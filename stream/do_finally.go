@@ -24,10 +24,12 @@ import (
 //	              detect it). A panic raised inside the pipeline upstream of this node (e.g. a
 //	              panicking mapper) is a drain failure and is reported as the recovered panic
 //	              error — equivalent to (wrapping the same panicked value as) the error the
-//	              consumer returns to the caller, though not the same error instance. This holds
-//	              on the sequential consume paths; options that drive the pipeline from their own
-//	              goroutines (WithConcurrentMapOption, WithConcurrentConsumeOption) do not yet
-//	              recover pipeline panics at all — see docs/shpanstream-concurrent-panic-followup.md.
+//	              consumer returns to the caller, though not the same error instance (both are a
+//	              *PanicError). This holds as well when the pipeline is driven by the background
+//	              goroutines of a concurrent operator or option (WithConcurrentMapOption,
+//	              WithConcurrentConsumeOption, Buffered, Merge, ...): the panic is recovered there and
+//	              fails the operator like any other pipeline error, so hooks downstream of it observe
+//	              it too.
 //	              A runtime.Goexit unwinding through the pipeline (e.g. t.FailNow inside a mapper)
 //	              abandons the drain rather than failing it: f fires with err == nil.
 //
@@ -453,3 +453,69 @@ func TestDoFinally_GoexitFiresNil(t *testing.T) {
 	require.Equal(t, 1, calls)
 	require.NoError(t, got)
 }
+
+// A pipeline panic under the concurrent consume is recovered on the producer goroutine: the caller
+// gets the recovered error (never a success), and the hook an equivalent one, same as sequentially.
+func TestDoFinally_ConcurrentConsumePipelinePanicFiresRecoveredError(t *testing.T) {
+	for range 50 {
+		var calls int
+		var got error
+
+		err := Map(FromSlice(makeRange(20)), panickingMapper).
+			DoFinally(func(e error) { calls++; got = e }).
+			Consume(context.Background(), func(v int) {}, WithConcurrentConsumeOption(3))
+
+		requirePanicError(t, err, "mapper boom")
+		require.Equal(t, 1, calls)
+		requirePanicError(t, got, "mapper boom")
+	}
+}
+
+// A consumer-callback panic in a worker goroutine is a delivery failure: the caller gets the
+// recovered error, the hook fires nil, same as sequentially.
+func TestDoFinally_ConcurrentConsumeCallbackPanicFiresNil(t *testing.T) {
+	var calls int
+	var got error = errors.New("sentinel-not-called")
+
+	err := FromSlice(makeRange(20)).
+		DoFinally(func(e error) { calls++; got = e }).
+		Consume(context.Background(), func(i int) { panickingMapper(i) }, WithConcurrentConsumeOption(3))
+
+	requirePanicError(t, err, "mapper boom")
+	require.Equal(t, 1, calls)
+	require.NoError(t, got)
+}
+
+// A pipeline panic under WithConcurrentMapOption is recovered on the reader or worker goroutines,
+// and fails the concurrent map stage: the hooks observe the outcome according to their locality.
+func TestDoFinally_ConcurrentMapPanicFiresLocally(t *testing.T) {
+	t.Run("upstream of the stage", func(t *testing.T) {
+		var upstream, downstream error
+		_, err := Map(
+			Map(FromSlice(makeRange(20)), panickingMapper).DoFinally(func(e error) { upstream = e }),
+			func(i int) int { return i },
+			WithConcurrentMapOption(2),
+		).
+			DoFinally(func(e error) { downstream = e }).
+			Collect(context.Background())
+
+		requirePanicError(t, err, "mapper boom")
+		requirePanicError(t, upstream, "mapper boom")
+		requirePanicError(t, downstream, "mapper boom")
+	})
+	t.Run("in the stage", func(t *testing.T) {
+		var upstream, downstream error = errors.New("sentinel-not-called"), nil
+		_, err := Map(
+			FromSlice(makeRange(20)).DoFinally(func(e error) { upstream = e }),
+			panickingMapper,
+			WithConcurrentMapOption(2),
+		).
+			DoFinally(func(e error) { downstream = e }).
+			Collect(context.Background())
+
+		requirePanicError(t, err, "mapper boom")
+		// The panic is downstream of the source, which is torn down cleanly
+		require.NoError(t, upstream)
+		requirePanicError(t, downstream, "mapper boom")
+	})
+}
@@ -0,0 +1,208 @@
+package stream
+
+import (
+	"context"
+	"github.com/shpandrak/shpanstream"
+	"github.com/shpandrak/shpanstream/internal/util"
+	"io"
+)
+
+// OnErrorResume switches to the stream created by fallbackFactory when the stream fails, either when opening
+// or emitting. Elements that were already emitted are kept, and the fallback continues from there.
+// Context cancellation and panics are not recovered.
+// A DoFinally hook attached upstream still observes the original error, while a hook attached downstream
+// observes the outcome of the fallback.
+func (s Stream[T]) OnErrorResume(fallbackFactory func(err error) Stream[T]) Stream[T] {
+	return NewStream[T](&onErrorResumeProvider[T]{src: s, fallbackFactory: fallbackFactory})
+}
+
+// OnErrorResumeWith switches to the fallback stream when the stream fails, see OnErrorResume.
+func (s Stream[T]) OnErrorResumeWith(fallback Stream[T]) Stream[T] {
+	return s.OnErrorResume(func(_ error) Stream[T] {
+		return fallback
+	})
+}
+
+// OnErrorReturn emits the value and completes when the stream fails, see OnErrorResume.
+func (s Stream[T]) OnErrorReturn(value T) Stream[T] {
+	return s.OnErrorResume(func(_ error) Stream[T] {
+		return Just(value)
+	})
+}
+
+// OnErrorComplete completes the stream normally when it fails, keeping the elements already emitted,
+// see OnErrorResume.
+func (s Stream[T]) OnErrorComplete() Stream[T] {
+	return s.OnErrorResume(func(_ error) Stream[T] {
+		return Empty[T]()
+	})
+}
+
+type onErrorResumeProvider[T any] struct {
+	src             Stream[T]
+	fallbackFactory func(err error) Stream[T]
+
+	// Per-consumption state, (re)initialised in Open and torn down in Close.
+	curr       *Stream[T]
+	currCancel context.CancelFunc
+	resumed    bool
+}
+
+func (op *onErrorResumeProvider[T]) Open(ctx context.Context) error {
+	op.resumed = false
+	err := op.openCurr(ctx, op.src)
+	if err != nil && ctx.Err() == nil {
+		return op.resume(ctx, err)
+	}
+	return err
+}
+
+func (op *onErrorResumeProvider[T]) Emit(ctx context.Context) (T, error) {
+	v, err := op.curr.provider(ctx)
+	if err == nil || err == io.EOF || op.resumed || ctx.Err() != nil {
+		return v, err
+	}
+	op.closeCurr()
+	if err := op.resume(ctx, err); err != nil {
+		return util.DefaultValue[T](), err
+	}
+	return op.curr.provider(ctx)
+}
+
+func (op *onErrorResumeProvider[T]) Close() {
+	op.closeCurr()
+}
+
+func (op *onErrorResumeProvider[T]) resume(ctx context.Context, err error) error {
+	op.resumed = true
+	return op.openCurr(ctx, op.fallbackFactory(err))
+}
+
+func (op *onErrorResumeProvider[T]) openCurr(ctx context.Context, s Stream[T]) error {
+	cancel, err := doOpenStream(ctx, s)
+	if err != nil {
+		return err
+	}
+	op.curr = &s
+	op.currCancel = cancel
+	return nil
+}
+
+func (op *onErrorResumeProvider[T]) closeCurr() {
+	if op.curr != nil {
+		doCloseSubStream(*op.curr)
+		op.currCancel()
+		op.curr = nil
+		op.currCancel = nil
+	}
+}
+
+// MapWithErrToResult maps the source stream using a mapper function that can fail, without failing the stream:
+// each mapper failure is emitted as a shpanstream.Result with the error, allowing the consumer to handle
+// per-element failures (e.g. route them to a dead-letter queue). Context cancellation still fails the stream.
+func MapWithErrToResult[SRC any, TGT any](
+	src Stream[SRC],
+	mapper shpanstream.MapperWithErr[SRC, TGT],
+	options ...MapOption,
+) Stream[shpanstream.Result[TGT]] {
+	return MapWithErrAndCtxToResult(src, mapper.ToErrCtx(), options...)
+}
+
+// MapWithErrAndCtxToResult is MapWithErrToResult, passing through the context to the mapper.
+func MapWithErrAndCtxToResult[SRC any, TGT any](
+	src Stream[SRC],
+	mapper shpanstream.MapperWithErrAndCtx[SRC, TGT],
+	options ...MapOption,
+) Stream[shpanstream.Result[TGT]] {
+	return Map(
+		mapWithErrToSourcedResult(src, mapper, options...),
+		func(sr sourcedResult[SRC, TGT]) shpanstream.Result[TGT] {
+			return sr.result
+		},
+	)
+}
+
+// MapWithErrSkipping maps the source stream using a mapper function that can fail, skipping the elements
+// that failed instead of failing the stream. Each skipped element is passed to the deadLetter callback
+// together with its error. deadLetter is always called sequentially, even when mapping concurrently.
+// Context cancellation still fails the stream.
+func MapWithErrSkipping[SRC any, TGT any](
+	src Stream[SRC],
+	mapper shpanstream.MapperWithErr[SRC, TGT],
+	deadLetter func(src SRC, err error),
+	options ...MapOption,
+) Stream[TGT] {
+	return MapWithErrAndCtxSkipping(src, mapper.ToErrCtx(), deadLetter, options...)
+}
+
+// MapWithErrAndCtxSkipping is MapWithErrSkipping, passing through the context to the mapper.
+func MapWithErrAndCtxSkipping[SRC any, TGT any](
+	src Stream[SRC],
+	mapper shpanstream.MapperWithErrAndCtx[SRC, TGT],
+	deadLetter func(src SRC, err error),
+	options ...MapOption,
+) Stream[TGT] {
+	return MapWhileFiltering(
+		mapWithErrToSourcedResult(src, mapper, options...),
+		func(sr sourcedResult[SRC, TGT]) *TGT {
+			if sr.result.Err != nil {
+				if deadLetter != nil {
+					deadLetter(sr.src, sr.result.Err)
+				}
+				return nil
+			}
+			return &sr.result.Value
+		},
+	)
+}
+
+// FilterWithErrSkipping filters the stream using a predicate that can fail, skipping the elements the
+// predicate failed for instead of failing the stream. Each skipped element is passed to the deadLetter
+// callback together with its error. Context cancellation still fails the stream.
+func (s Stream[T]) FilterWithErrSkipping(predicate shpanstream.PredicateWithErr[T], deadLetter func(v T, err error)) Stream[T] {
+	return newStream[T](func(ctx context.Context) (T, error) {
+		for {
+			v, err := s.provider(ctx)
+			if err != nil {
+				return v, err
+			}
+			shouldKeep, err := predicate(v)
+			if err != nil {
+				if ctx.Err() != nil {
+					return util.DefaultValue[T](), ctx.Err()
+				}
+				if deadLetter != nil {
+					deadLetter(v, err)
+				}
+				continue
+			}
+			if shouldKeep {
+				return v, nil
+			}
+		}
+	}, s.allLifecycleElement)
+}
+
+type sourcedResult[SRC any, TGT any] struct {
+	src    SRC
+	result shpanstream.Result[TGT]
+}
+
+func mapWithErrToSourcedResult[SRC any, TGT any](
+	src Stream[SRC],
+	mapper shpanstream.MapperWithErrAndCtx[SRC, TGT],
+	options ...MapOption,
+) Stream[sourcedResult[SRC, TGT]] {
+	return MapWithErrAndCtx(
+		src,
+		func(ctx context.Context, v SRC) (sourcedResult[SRC, TGT], error) {
+			tgt, err := mapper(ctx, v)
+			if err != nil && ctx.Err() != nil {
+				// Cancellation is not a per-element failure
+				return sourcedResult[SRC, TGT]{}, err
+			}
+			return sourcedResult[SRC, TGT]{src: v, result: shpanstream.Result[TGT]{Value: tgt, Err: err}}, nil
+		},
+		options...,
+	)
+}
@@ -0,0 +1,174 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/shpandrak/shpanstream"
+	"github.com/stretchr/testify/require"
+	"strconv"
+	"testing"
+)
+
+func TestOnErrorResumeWith_EmitFailure(t *testing.T) {
+	boom := errors.New("boom")
+	require.Equal(
+		t,
+		[]int{1, 2, 100, 200},
+		errAt(Just(1, 2, 3, 4), 3, boom).OnErrorResumeWith(Just(100, 200)).MustCollect(),
+	)
+}
+
+func TestOnErrorResumeWith_OpenFailure(t *testing.T) {
+	require.Equal(
+		t,
+		[]int{100},
+		Error[int](errors.New("boom")).OnErrorResumeWith(Just(100)).MustCollect(),
+	)
+}
+
+func TestOnErrorResume_FallbackGetsError(t *testing.T) {
+	boom := errors.New("boom")
+	var got error
+	res := errAt(Just(1, 2), 2, boom).OnErrorResume(func(err error) Stream[int] {
+		got = err
+		return Just(-1)
+	}).MustCollect()
+	require.Equal(t, []int{1, -1}, res)
+	require.ErrorIs(t, got, boom)
+}
+
+func TestOnErrorResume_FallbackFailureIsNotRecovered(t *testing.T) {
+	boom := errors.New("boom")
+	fallbackErr := errors.New("fallback failed")
+	_, err := errAt(Just(1, 2), 2, boom).OnErrorResumeWith(Error[int](fallbackErr)).Collect(context.Background())
+	require.ErrorIs(t, err, fallbackErr)
+
+	_, err = errAt(Just(1, 2), 2, boom).OnErrorResumeWith(errAt(Just(5, 6), 6, fallbackErr)).Collect(context.Background())
+	require.ErrorIs(t, err, fallbackErr)
+}
+
+func TestOnErrorReturn(t *testing.T) {
+	boom := errors.New("boom")
+	require.Equal(t, []int{1, 2, -1}, errAt(Just(1, 2, 3, 4), 3, boom).OnErrorReturn(-1).MustCollect())
+
+	// No error, no value
+	require.Equal(t, []int{1, 2}, Just(1, 2).OnErrorReturn(-1).MustCollect())
+}
+
+func TestOnErrorComplete(t *testing.T) {
+	boom := errors.New("boom")
+	require.Equal(t, []int{1, 2}, errAt(Just(1, 2, 3, 4), 3, boom).OnErrorComplete().MustCollect())
+}
+
+func TestOnErrorResume_ContextCancellationIsNotRecovered(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := MapWithErrAndCtx(Just(1, 2, 3), func(ctx context.Context, v int) (int, error) {
+		if v == 2 {
+			cancel()
+			return 0, ctx.Err()
+		}
+		return v, nil
+	}).OnErrorReturn(-1)
+	_, err := s.Collect(ctx)
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestOnErrorResume_DoFinallyOutcomes(t *testing.T) {
+	boom := errors.New("boom")
+	var upstreamErr, downstreamErr error
+	upstreamCalls, downstreamCalls := 0, 0
+
+	res := errAt(Just(1, 2, 3), 2, boom).
+		DoFinally(func(err error) {
+			upstreamCalls++
+			upstreamErr = err
+		}).
+		OnErrorReturn(-1).
+		DoFinally(func(err error) {
+			downstreamCalls++
+			downstreamErr = err
+		}).
+		MustCollect()
+
+	require.Equal(t, []int{1, -1}, res)
+	require.Equal(t, 1, upstreamCalls)
+	require.ErrorIs(t, upstreamErr, boom)
+	require.Equal(t, 1, downstreamCalls)
+	require.NoError(t, downstreamErr)
+}
+
+func TestOnErrorResume_Reconsumption(t *testing.T) {
+	boom := errors.New("boom")
+	s := errAt(Just(1, 2, 3), 2, boom).OnErrorReturn(-1)
+	require.Equal(t, []int{1, -1}, s.MustCollect())
+	require.Equal(t, []int{1, -1}, s.MustCollect())
+}
+
+func TestMapWithErrSkipping(t *testing.T) {
+	var deadLetters []string
+	res := MapWithErrSkipping(
+		Just("1", "x", "3", "y"),
+		strconv.Atoi,
+		func(src string, err error) {
+			require.Error(t, err)
+			deadLetters = append(deadLetters, src)
+		},
+	).MustCollect()
+	require.Equal(t, []int{1, 3}, res)
+	require.Equal(t, []string{"x", "y"}, deadLetters)
+}
+
+func TestMapWithErrSkipping_Concurrent(t *testing.T) {
+	deadLetters := 0
+	res := MapWithErrSkipping(
+		FromSlice(makeRange(100)),
+		func(v int) (int, error) {
+			if v%10 == 0 {
+				return 0, fmt.Errorf("bad value %d", v)
+			}
+			return v, nil
+		},
+		func(_ int, _ error) {
+			// Called sequentially, no need to synchronize
+			deadLetters++
+		},
+		WithConcurrentMapOption(4),
+	).MustCount()
+	require.Equal(t, 90, res)
+	require.Equal(t, 10, deadLetters)
+}
+
+func TestMapWithErrToResult(t *testing.T) {
+	res := MapWithErrToResult(Just("1", "x", "3"), strconv.Atoi).MustCollect()
+	require.Len(t, res, 3)
+	require.Equal(t, shpanstream.Result[int]{Value: 1}, res[0])
+	require.Error(t, res[1].Err)
+	require.Equal(t, shpanstream.Result[int]{Value: 3}, res[2])
+}
+
+func TestMapWithErrAndCtxToResult_ContextCancellationFails(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	_, err := MapWithErrAndCtxToResult(Just(1, 2, 3), func(ctx context.Context, v int) (int, error) {
+		cancel()
+		return 0, ctx.Err()
+	}).Collect(ctx)
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestFilterWithErrSkipping(t *testing.T) {
+	var deadLetters []int
+	res := Just(1, 2, 3, 4, 5, 6).FilterWithErrSkipping(
+		func(v int) (bool, error) {
+			if v == 3 {
+				return false, errors.New("bad value")
+			}
+			return v%2 == 1, nil
+		},
+		func(v int, _ error) {
+			deadLetters = append(deadLetters, v)
+		},
+	).MustCollect()
+	require.Equal(t, []int{1, 5}, res)
+	require.Equal(t, []int{3}, deadLetters)
+}
@@ -0,0 +1,277 @@
+package stream
+
+import (
+	"context"
+	"fmt"
+	"github.com/shpandrak/shpanstream"
+	"github.com/shpandrak/shpanstream/internal/util"
+	"io"
+	"sync"
+)
+
+// flatMapConcurrentInnerBufferSize is the number of elements buffered for each inner stream by
+// FlatMapConcurrentOrdered, while it waits for the inner streams before it to be emitted.
+const flatMapConcurrentInnerBufferSize = 64
+
+// FlatMapConcurrent maps each element of the source stream to an inner stream, and flattens the inner streams
+// into a single stream, like FlatMap. Up to concurrency inner streams are consumed at the same time (each on
+// its own goroutine), and their elements are interleaved in the order they arrive.
+// Inner streams are opened and closed on their own goroutine. Once the stream terminates (completed, failed,
+// truncated by e.g. Limit, or cancelled), all inner streams are closed before the stream reports closed.
+func FlatMapConcurrent[SRC any, TGT any](
+	src Stream[SRC],
+	mapper shpanstream.Mapper[SRC, Stream[TGT]],
+	concurrency int,
+) Stream[TGT] {
+	return flatMapConcurrently(src, mapper, concurrency, false)
+}
+
+// FlatMapConcurrentOrdered is like FlatMapConcurrent, but concatenates the inner streams in source order (the
+// same result as FlatMap). Up to concurrency inner streams are consumed ahead of time, buffering a bounded
+// number of elements each until the inner streams before them are emitted.
+func FlatMapConcurrentOrdered[SRC any, TGT any](
+	src Stream[SRC],
+	mapper shpanstream.Mapper[SRC, Stream[TGT]],
+	concurrency int,
+) Stream[TGT] {
+	return flatMapConcurrently(src, mapper, concurrency, true)
+}
+
+func flatMapConcurrently[SRC any, TGT any](
+	src Stream[SRC],
+	mapper shpanstream.Mapper[SRC, Stream[TGT]],
+	concurrency int,
+	ordered bool,
+) Stream[TGT] {
+	if concurrency <= 0 {
+		return Error[TGT](fmt.Errorf("concurrency must be > 0"))
+	}
+	fp := &flatMapConcurrentProvider[SRC, TGT]{
+		src:         src,
+		mapper:      mapper,
+		concurrency: concurrency,
+		ordered:     ordered,
+	}
+	// Same as the concurrent mapper, the provider owns the source lifecycle so that close can join the
+	// background goroutines BEFORE closing the source.
+	return NewSimpleStream(fp.emit, WithOpenFuncOption(fp.open), WithCloseFuncOption(fp.close))
+}
+
+// flatMapInner is an inner stream consumed ahead of time by the ordered variant.
+type flatMapInner[TGT any] struct {
+	ch chan TGT
+	// err is set by the inner goroutine before closing ch, nil means the inner stream completed normally
+	err error
+}
+
+type flatMapConcurrentProvider[SRC any, TGT any] struct {
+	src         Stream[SRC]
+	mapper      shpanstream.Mapper[SRC, Stream[TGT]]
+	concurrency int
+	ordered     bool
+
+	// Per-consumption state, (re)initialised in open and torn down in close.
+	// sem bounds the number of inner streams in progress. When unordered, a token is returned once the inner
+	// stream is consumed, and when ordered, once it was also emitted.
+	sem chan struct{}
+	// resultsChan is used when unordered, innersChan (and curr) when ordered.
+	resultsChan    chan shpanstream.Result[TGT]
+	innersChan     chan *flatMapInner[TGT]
+	curr           *flatMapInner[TGT]
+	eofCtx         context.Context
+	eofCancel      context.CancelFunc
+	internalCancel context.CancelFunc
+	srcCancel      context.CancelFunc
+	producerDone   chan struct{}
+}
+
+func (fp *flatMapConcurrentProvider[SRC, TGT]) open(ctx context.Context) error {
+	srcCancel, err := doOpenStream[SRC](ctx, fp.src)
+	if err != nil {
+		return err
+	}
+	fp.srcCancel = srcCancel
+
+	fp.sem = make(chan struct{}, fp.concurrency)
+	fp.resultsChan = make(chan shpanstream.Result[TGT], fp.concurrency)
+	fp.innersChan = make(chan *flatMapInner[TGT], fp.concurrency)
+	fp.curr = nil
+
+	// internalCtx bounds the reader + inner goroutines; close cancels it and waits for them.
+	internalCtx, internalCancel := context.WithCancel(ctx)
+	fp.internalCancel = internalCancel
+
+	// eofCtx lets emit distinguish "closed because the source reached EOF" from "closed on cancel".
+	eofCtx, eofCancelFunc := context.WithCancel(context.Background())
+	fp.eofCtx = eofCtx
+	fp.eofCancel = eofCancelFunc
+
+	// Closed only after the reader and every inner goroutine have fully exited, so close can join on it.
+	fp.producerDone = make(chan struct{})
+
+	go fp.read(internalCtx)
+	return nil
+}
+
+// read pulls the source, and starts consuming an inner stream for each element once there is room.
+func (fp *flatMapConcurrentProvider[SRC, TGT]) read(ctx context.Context) {
+	defer close(fp.producerDone)
+
+	var wg sync.WaitGroup
+	defer func() {
+		// Wait for the inner streams to be fully consumed (and closed) before closing the channel
+		wg.Wait()
+		if fp.ordered {
+			close(fp.innersChan)
+		} else {
+			close(fp.resultsChan)
+		}
+	}()
+
+	for {
+		select {
+		case fp.sem <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+		// A panic in the source or the mapper fails the stream as the source error
+		inner, err := callWithPanicGuard(func() (Stream[TGT], error) {
+			v, err := fp.src.provider(ctx)
+			if err != nil {
+				return Stream[TGT]{}, err
+			}
+			return fp.mapper(v), nil
+		})
+		if err != nil {
+			if err == io.EOF {
+				<-fp.sem
+				fp.eofCancel()
+				return
+			}
+			fp.sendErr(ctx, err)
+			return
+		}
+
+		wg.Add(1)
+		if fp.ordered {
+			in := &flatMapInner[TGT]{ch: make(chan TGT, flatMapConcurrentInnerBufferSize)}
+			// Never blocks, since the inner streams are bounded by sem
+			fp.innersChan <- in
+			go func() {
+				defer wg.Done()
+				defer close(in.ch)
+				in.err = inner.ConsumeWithErr(ctx, func(t TGT) error {
+					select {
+					case in.ch <- t:
+						return nil
+					case <-ctx.Done():
+						return ctx.Err()
+					}
+				})
+			}()
+		} else {
+			go func() {
+				defer wg.Done()
+				defer func() { <-fp.sem }()
+				err := inner.ConsumeWithErr(ctx, func(t TGT) error {
+					select {
+					case fp.resultsChan <- shpanstream.Result[TGT]{Value: t}:
+						return nil
+					case <-ctx.Done():
+						return ctx.Err()
+					}
+				})
+				if err != nil && ctx.Err() == nil {
+					fp.sendErr(ctx, err)
+				}
+			}()
+		}
+	}
+}
+
+// sendErr passes the error to emit. When ordered, the error is queued after the inner streams read so far,
+// holding the sem token taken for it.
+func (fp *flatMapConcurrentProvider[SRC, TGT]) sendErr(ctx context.Context, err error) {
+	if fp.ordered {
+		in := &flatMapInner[TGT]{ch: make(chan TGT), err: err}
+		close(in.ch)
+		fp.innersChan <- in
+		return
+	}
+	select {
+	case fp.resultsChan <- shpanstream.Result[TGT]{Err: err}:
+	case <-ctx.Done():
+	}
+}
+
+func (fp *flatMapConcurrentProvider[SRC, TGT]) emit(ctx context.Context) (TGT, error) {
+	if !fp.ordered {
+		select {
+		case <-ctx.Done():
+			return util.DefaultValue[TGT](), ctx.Err()
+		case r, stillGood := <-fp.resultsChan:
+			if !stillGood {
+				return util.DefaultValue[TGT](), fp.closedErr(ctx)
+			}
+			return r.Unpack()
+		}
+	}
+
+	for {
+		if fp.curr == nil {
+			select {
+			case <-ctx.Done():
+				return util.DefaultValue[TGT](), ctx.Err()
+			case in, stillGood := <-fp.innersChan:
+				if !stillGood {
+					return util.DefaultValue[TGT](), fp.closedErr(ctx)
+				}
+				fp.curr = in
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return util.DefaultValue[TGT](), ctx.Err()
+		case t, stillGood := <-fp.curr.ch:
+			if stillGood {
+				return t, nil
+			}
+			// The inner stream is drained, err was set before closing the channel
+			err := fp.curr.err
+			fp.curr = nil
+			<-fp.sem
+			if err != nil {
+				return util.DefaultValue[TGT](), err
+			}
+		}
+	}
+}
+
+func (fp *flatMapConcurrentProvider[SRC, TGT]) closedErr(ctx context.Context) error {
+	if fp.eofCtx.Err() != nil {
+		return io.EOF
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	// Should never happen
+	return fmt.Errorf("flat map concurrent stream channel closed prematurely")
+}
+
+func (fp *flatMapConcurrentProvider[SRC, TGT]) close() {
+	// Stop the reader + inner goroutines and wait for them to fully exit (closing the inner streams) BEFORE
+	// closing the source, so nothing drives the source's provider concurrently with its Close.
+	if fp.internalCancel != nil {
+		fp.internalCancel()
+	}
+	if fp.producerDone != nil {
+		<-fp.producerDone
+	}
+	doCloseSubStream[SRC](fp.src)
+	if fp.srcCancel != nil {
+		fp.srcCancel()
+	}
+	if fp.eofCancel != nil {
+		fp.eofCancel()
+	}
+}
@@ -0,0 +1,198 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"sort"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// flatMapTestTracker creates inner streams, tracking how many are open at the same time.
+type flatMapTestTracker struct {
+	open     atomic.Int32
+	maxOpen  atomic.Int32
+	opened   atomic.Int32
+	closed   atomic.Int32
+	innerLen int
+}
+
+func (ft *flatMapTestTracker) inner(n int) Stream[int] {
+	i := 0
+	return NewSimpleStream(
+		func(ctx context.Context) (int, error) {
+			if i >= ft.innerLen {
+				return 0, io.EOF
+			}
+			// Uneven inner streams, so their elements interleave
+			time.Sleep(time.Duration(rand.Intn(100)) * time.Microsecond)
+			i++
+			return n*100 + i - 1, nil
+		},
+		WithOpenFuncOption(func(ctx context.Context) error {
+			i = 0
+			ft.opened.Add(1)
+			curr := ft.open.Add(1)
+			for {
+				prev := ft.maxOpen.Load()
+				if curr <= prev || ft.maxOpen.CompareAndSwap(prev, curr) {
+					break
+				}
+			}
+			return nil
+		}),
+		WithCloseFuncOption(func() {
+			ft.open.Add(-1)
+			ft.closed.Add(1)
+		}),
+	)
+}
+
+func flatMapTestExpected(n int, innerLen int) []int {
+	var ret []int
+	for i := 0; i < n; i++ {
+		for j := 0; j < innerLen; j++ {
+			ret = append(ret, i*100+j)
+		}
+	}
+	return ret
+}
+
+func TestFlatMapConcurrent(t *testing.T) {
+	ft := &flatMapTestTracker{innerLen: 10}
+	out, err := FlatMapConcurrent(FromSlice(makeRange(20)), ft.inner, 4).Collect(context.Background())
+	require.NoError(t, err)
+	sort.Ints(out)
+	require.Equal(t, flatMapTestExpected(20, 10), out)
+	require.LessOrEqual(t, ft.maxOpen.Load(), int32(4))
+	require.EqualValues(t, 20, ft.opened.Load())
+	require.EqualValues(t, 20, ft.closed.Load())
+}
+
+func TestFlatMapConcurrentOrdered(t *testing.T) {
+	ft := &flatMapTestTracker{innerLen: 20}
+	s := FlatMapConcurrentOrdered(FromSlice(makeRange(20)), ft.inner, 4)
+	for i := 0; i < 2; i++ {
+		out, err := s.Collect(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, flatMapTestExpected(20, 20), out)
+	}
+	require.LessOrEqual(t, ft.maxOpen.Load(), int32(4))
+	require.EqualValues(t, 40, ft.opened.Load())
+	require.EqualValues(t, 40, ft.closed.Load())
+}
+
+func TestFlatMapConcurrent_InnerStreamsClosedOnEarlyTermination(t *testing.T) {
+	for _, ordered := range []bool{false, true} {
+		for i := 0; i < 50; i++ {
+			ft := &flatMapTestTracker{innerLen: 1000}
+			out, err := flatMapConcurrently(FromSlice(makeRange(20)), ft.inner, 4, ordered).
+				Limit(5).
+				Collect(context.Background())
+			require.NoError(t, err)
+			require.Len(t, out, 5)
+			require.Equal(t, ft.opened.Load(), ft.closed.Load())
+			require.EqualValues(t, 0, ft.open.Load())
+		}
+	}
+}
+
+func TestFlatMapConcurrent_Errors(t *testing.T) {
+	boom := errors.New("boom")
+	for _, ordered := range []bool{false, true} {
+		ft := &flatMapTestTracker{innerLen: 10}
+		_, err := flatMapConcurrently(
+			FromSlice(makeRange(20)),
+			func(n int) Stream[int] {
+				if n == 7 {
+					return errAt(ft.inner(n), 705, boom)
+				}
+				return ft.inner(n)
+			},
+			4,
+			ordered,
+		).Collect(context.Background())
+		require.ErrorIs(t, err, boom)
+		require.Equal(t, ft.opened.Load(), ft.closed.Load())
+
+		// Source failure
+		ft = &flatMapTestTracker{innerLen: 10}
+		var got []int
+		err = flatMapConcurrently(errAt(FromSlice(makeRange(20)), 3, boom), ft.inner, 2, ordered).
+			Consume(context.Background(), func(v int) {
+				got = append(got, v)
+			})
+		require.ErrorIs(t, err, boom)
+		if ordered {
+			// Everything before the failure is emitted in order
+			require.Equal(t, flatMapTestExpected(3, 10), got)
+		}
+		require.Equal(t, ft.opened.Load(), ft.closed.Load())
+	}
+}
+
+func TestFlatMapConcurrent_Panics(t *testing.T) {
+	for _, ordered := range []bool{false, true} {
+		// A panicking mapper, recovered on the reader goroutine
+		ft := &flatMapTestTracker{innerLen: 10}
+		_, err := flatMapConcurrently(
+			FromSlice(makeRange(20)),
+			func(n int) Stream[int] {
+				if n == 7 {
+					panic("mapper boom")
+				}
+				return ft.inner(n)
+			},
+			4,
+			ordered,
+		).Collect(context.Background())
+		requirePanicError(t, err, "mapper boom")
+		require.Equal(t, ft.opened.Load(), ft.closed.Load())
+
+		// A panic in an inner stream, recovered by its consumption on the inner goroutine
+		ft = &flatMapTestTracker{innerLen: 10}
+		_, err = flatMapConcurrently(
+			FromSlice(makeRange(20)),
+			func(n int) Stream[int] {
+				return Map(ft.inner(n), func(v int) int {
+					if v == 705 {
+						panic("inner boom")
+					}
+					return v
+				})
+			},
+			4,
+			ordered,
+		).Collect(context.Background())
+		requirePanicError(t, err, "inner boom")
+		require.Equal(t, ft.opened.Load(), ft.closed.Load())
+	}
+}
+
+func TestFlatMapConcurrent_Cancellation(t *testing.T) {
+	for _, ordered := range []bool{false, true} {
+		ft := &flatMapTestTracker{innerLen: 1000}
+		ctx, cancel := context.WithCancel(context.Background())
+		got := 0
+		err := flatMapConcurrently(FromSlice(makeRange(20)), ft.inner, 4, ordered).
+			Consume(ctx, func(v int) {
+				got++
+				if got == 10 {
+					cancel()
+				}
+			})
+		require.ErrorIs(t, err, context.Canceled)
+		require.Equal(t, ft.opened.Load(), ft.closed.Load())
+		cancel()
+	}
+}
+
+func TestFlatMapConcurrent_InvalidConcurrency(t *testing.T) {
+	_, err := FlatMapConcurrent(Just(1), func(v int) Stream[int] { return Just(v) }, 0).Collect(context.Background())
+	require.Error(t, err)
+}
@@ -0,0 +1,466 @@
+package stream
+
+import (
+	"context"
+	"fmt"
+	"github.com/shpandrak/shpanstream"
+	"github.com/shpandrak/shpanstream/internal/util"
+	"io"
+	"sync"
+)
+
+// DefaultGroupByBufferSize is the default number of elements buffered for each group.
+const DefaultGroupByBufferSize = 64
+
+type GroupByOption func(*groupByConfig)
+
+type groupByConfig struct {
+	bufferSize    int
+	maxOpenGroups int
+	concurrency   int
+}
+
+// WithGroupByBufferSizeOption sets the number of elements buffered for each group, default is
+// DefaultGroupByBufferSize. When a group buffer is full, the source is not read until the group is consumed.
+func WithGroupByBufferSizeOption(size int) GroupByOption {
+	return func(cfg *groupByConfig) {
+		cfg.bufferSize = size
+	}
+}
+
+// WithGroupByMaxOpenGroupsOption bounds the number of groups open at the same time. When an element with a new
+// key arrives and the limit is reached, the least recently active group is completed (its stream ends) to make
+// room. A later element of a completed group's key opens a new group for that key, so a key might be emitted
+// more than once. By default, the number of open groups is unbounded.
+func WithGroupByMaxOpenGroupsOption(maxOpenGroups int) GroupByOption {
+	return func(cfg *groupByConfig) {
+		cfg.maxOpenGroups = maxOpenGroups
+	}
+}
+
+// WithGroupByConcurrencyOption sets the maximal number of reducers GroupByReduce runs concurrently. It only limits
+// the parallelism, not the grouping: a group waiting for a reducer stays open, and its elements are buffered (in
+// memory, unbounded) until its reducer starts, so every key is still reduced once. To bound the number of open
+// groups, see WithGroupByMaxOpenGroupsOption. By default, every group is reduced as soon as it opens.
+func WithGroupByConcurrencyOption(concurrency int) GroupByOption {
+	return func(cfg *groupByConfig) {
+		cfg.concurrency = concurrency
+	}
+}
+
+// GroupBy splits an unsorted stream into keyed groups, emitting an entry with a sub-stream for every new key.
+// Each group stream emits the elements of its key in source order, as they are read from the source. The
+// source is read (on a background goroutine) once the grouped stream is opened, and groups complete when the
+// source is done (or when evicted, see WithGroupByMaxOpenGroupsOption).
+// Elements are dispatched to buffered groups (see WithGroupByBufferSizeOption), so groups are expected to be
+// consumed concurrently with the grouped stream and with each other (e.g. each on its own goroutine), see
+// GroupByReduce. A group stream that is closed without being fully consumed has its remaining elements dropped.
+// Closing the grouped stream stops reading the source, and fails the groups that did not complete yet.
+// Group streams are single-use, and can be consumed only once.
+// For sorted streams, ClusterSortedStream groups adjacent elements without buffering.
+func GroupBy[T any, K comparable](
+	s Stream[T],
+	keyFunc func(T) K,
+	opts ...GroupByOption,
+) Stream[shpanstream.Entry[K, Stream[T]]] {
+	cfg, err := newGroupByConfig(opts)
+	if err != nil {
+		return Error[shpanstream.Entry[K, Stream[T]]](err)
+	}
+	return newGroupByStream(s, keyFunc, cfg, false)
+}
+
+func newGroupByStream[T any, K comparable](
+	s Stream[T],
+	keyFunc func(T) K,
+	cfg groupByConfig,
+	backlogUnopened bool,
+) Stream[shpanstream.Entry[K, Stream[T]]] {
+	gp := &groupByProvider[T, K]{
+		src:             s,
+		keyFunc:         keyFunc,
+		bufferSize:      cfg.bufferSize,
+		maxOpenGroups:   cfg.maxOpenGroups,
+		backlogUnopened: backlogUnopened,
+	}
+	return NewSimpleStream(gp.emit, WithOpenFuncOption(gp.open), WithCloseFuncOption(gp.close))
+}
+
+// GroupByReduce splits an unsorted stream into keyed groups (see GroupBy), and reduces each group concurrently
+// using the reducer, emitting the reducer results in the order the groups are done.
+// Each group stream is closed once its reducer returns, even if the reducer did not consume it.
+func GroupByReduce[T any, K comparable, O any](
+	s Stream[T],
+	keyFunc func(T) K,
+	reducer func(ctx context.Context, key K, group Stream[T]) (O, error),
+	opts ...GroupByOption,
+) Stream[O] {
+	cfg, err := newGroupByConfig(opts)
+	if err != nil {
+		return Error[O](err)
+	}
+	rp := &groupByReduceProvider[T, K, O]{
+		// Groups waiting for a reducer keep buffering, so they never block the groups being reduced
+		grouped:     newGroupByStream(s, keyFunc, cfg, cfg.concurrency > 0),
+		reducer:     reducer,
+		concurrency: cfg.concurrency,
+	}
+	return NewSimpleStream(rp.emit, WithOpenFuncOption(rp.open), WithCloseFuncOption(rp.close))
+}
+
+func newGroupByConfig(opts []GroupByOption) (groupByConfig, error) {
+	cfg := groupByConfig{
+		bufferSize: DefaultGroupByBufferSize,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.bufferSize <= 0 {
+		return cfg, fmt.Errorf("group by buffer size must be greater than 0")
+	}
+	if cfg.maxOpenGroups < 0 {
+		return cfg, fmt.Errorf("group by max open groups must be greater than 0")
+	}
+	if cfg.concurrency < 0 {
+		return cfg, fmt.Errorf("group by concurrency must be greater than 0")
+	}
+	return cfg, nil
+}
+
+type groupByProvider[T any, K comparable] struct {
+	src           Stream[T]
+	keyFunc       func(T) K
+	bufferSize    int
+	maxOpenGroups int
+	// backlogUnopened buffers the elements of the groups that were not opened yet without bounds, rather than
+	// blocking the source once their buffer is full
+	backlogUnopened bool
+
+	// Per-consumption state, (re)initialised in open and torn down in close.
+	groupsChan     chan shpanstream.Entry[K, Stream[T]]
+	internalCancel context.CancelFunc
+	pumpDone       chan struct{}
+	// completed and err are set by the pump before closing groupsChan
+	completed bool
+	err       error
+}
+
+func (gp *groupByProvider[T, K]) open(ctx context.Context) error {
+	internalCtx, cancel := context.WithCancel(ctx)
+	gp.groupsChan = make(chan shpanstream.Entry[K, Stream[T]])
+	gp.internalCancel = cancel
+	gp.pumpDone = make(chan struct{})
+	gp.completed = false
+	gp.err = nil
+	go gp.pump(internalCtx)
+	return nil
+}
+
+func (gp *groupByProvider[T, K]) pump(ctx context.Context) {
+	defer close(gp.pumpDone)
+
+	groups := map[K]*groupByGroup[T, K]{}
+	seq := 0
+	err := gp.src.ConsumeWithErr(ctx, func(v T) error {
+		k := gp.keyFunc(v)
+		g, ok := groups[k]
+		if !ok {
+			if gp.maxOpenGroups > 0 && len(groups) >= gp.maxOpenGroups {
+				gp.evictLeastRecentlyActive(groups)
+			}
+			g = &groupByGroup[T, K]{
+				key:             k,
+				ch:              make(chan T, gp.bufferSize),
+				detached:        make(chan struct{}),
+				backlogUnopened: gp.backlogUnopened,
+			}
+			groups[k] = g
+			select {
+			case gp.groupsChan <- shpanstream.Entry[K, Stream[T]]{Key: k, Value: g.stream()}:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		seq++
+		g.lastActive = seq
+		if g.addToBacklog(v) {
+			return nil
+		}
+		select {
+		case g.ch <- v:
+		case <-g.detached:
+			// The group consumer is gone, drop the element
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		return nil
+	})
+	if err != nil {
+		err = fmt.Errorf("group by source failed: %w", err)
+	}
+	for _, g := range groups {
+		g.complete(err)
+	}
+	gp.err = err
+	gp.completed = true
+	close(gp.groupsChan)
+}
+
+// evictLeastRecentlyActive completes the group that received an element the longest time ago.
+func (gp *groupByProvider[T, K]) evictLeastRecentlyActive(groups map[K]*groupByGroup[T, K]) {
+	var lru *groupByGroup[T, K]
+	for _, g := range groups {
+		if lru == nil || g.lastActive < lru.lastActive {
+			lru = g
+		}
+	}
+	if lru != nil {
+		lru.complete(nil)
+		delete(groups, lru.key)
+	}
+}
+
+func (gp *groupByProvider[T, K]) emit(ctx context.Context) (shpanstream.Entry[K, Stream[T]], error) {
+	select {
+	case <-ctx.Done():
+		return util.DefaultValue[shpanstream.Entry[K, Stream[T]]](), ctx.Err()
+	case e, stillGood := <-gp.groupsChan:
+		if !stillGood {
+			// completed and err were set before closing the channel
+			if gp.completed {
+				if gp.err != nil {
+					return util.DefaultValue[shpanstream.Entry[K, Stream[T]]](), gp.err
+				}
+				return util.DefaultValue[shpanstream.Entry[K, Stream[T]]](), io.EOF
+			}
+			// Should never happen
+			return util.DefaultValue[shpanstream.Entry[K, Stream[T]]](), fmt.Errorf("group by stream channel closed prematurely")
+		}
+		return e, nil
+	}
+}
+
+func (gp *groupByProvider[T, K]) close() {
+	// Stop the pump and join it, so the source is fully closed by the time the stream reports closed
+	if gp.internalCancel != nil {
+		gp.internalCancel()
+		<-gp.pumpDone
+		gp.internalCancel = nil
+	}
+}
+
+type groupByGroup[T any, K comparable] struct {
+	key K
+	ch  chan T
+	// err is set by the pump before closing ch, nil means the group completed normally
+	err error
+
+	// lastActive is the sequence number of the last element dispatched to the group, owned by the pump
+	lastActive int
+
+	// detached is closed once the group stream is closed, so the pump stops feeding it
+	detached chan struct{}
+	mu       sync.Mutex
+	opened   bool
+	closed   bool
+
+	// backlog holds the elements dispatched before the group was opened, when backlogUnopened is set. They
+	// precede the elements of ch, since nothing is sent to ch before the group is opened.
+	backlogUnopened bool
+	backlog         []T
+}
+
+func (g *groupByGroup[T, K]) stream() Stream[T] {
+	return NewSimpleStream(g.emit, WithOpenFuncOption(g.open), WithCloseFuncOption(g.close))
+}
+
+func (g *groupByGroup[T, K]) complete(err error) {
+	g.err = err
+	close(g.ch)
+}
+
+// addToBacklog adds the element to the backlog if the group is still waiting to be opened, returning false if it
+// should be sent to the group channel instead.
+func (g *groupByGroup[T, K]) addToBacklog(v T) bool {
+	if !g.backlogUnopened {
+		return false
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.opened || g.closed {
+		return false
+	}
+	g.backlog = append(g.backlog, v)
+	return true
+}
+
+func (g *groupByGroup[T, K]) open(_ context.Context) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.opened {
+		return fmt.Errorf("group %v can only be consumed once", g.key)
+	}
+	g.opened = true
+	return nil
+}
+
+func (g *groupByGroup[T, K]) emit(ctx context.Context) (T, error) {
+	g.mu.Lock()
+	if len(g.backlog) > 0 {
+		v := g.backlog[0]
+		g.backlog[0] = util.DefaultValue[T]()
+		g.backlog = g.backlog[1:]
+		g.mu.Unlock()
+		return v, nil
+	}
+	g.mu.Unlock()
+	select {
+	case <-ctx.Done():
+		return util.DefaultValue[T](), ctx.Err()
+	case v, stillGood := <-g.ch:
+		if !stillGood {
+			// err was set before closing the channel
+			if g.err != nil {
+				return util.DefaultValue[T](), g.err
+			}
+			return util.DefaultValue[T](), io.EOF
+		}
+		return v, nil
+	}
+}
+
+func (g *groupByGroup[T, K]) close() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !g.closed {
+		g.closed = true
+		g.backlog = nil
+		close(g.detached)
+	}
+}
+
+type groupByReduceProvider[T any, K comparable, O any] struct {
+	grouped     Stream[shpanstream.Entry[K, Stream[T]]]
+	reducer     func(ctx context.Context, key K, group Stream[T]) (O, error)
+	concurrency int
+
+	// Per-consumption state, (re)initialised in open and torn down in close.
+	resultsChan    chan shpanstream.Result[O]
+	groupedCancel  context.CancelFunc
+	internalCancel context.CancelFunc
+	dispatchDone   chan struct{}
+	// completed is set by the dispatcher before closing resultsChan, once all groups were reduced
+	completed bool
+}
+
+func (rp *groupByReduceProvider[T, K, O]) open(ctx context.Context) error {
+	groupedCancel, err := doOpenStream(ctx, rp.grouped)
+	if err != nil {
+		return err
+	}
+	internalCtx, cancel := context.WithCancel(ctx)
+	rp.groupedCancel = groupedCancel
+	rp.internalCancel = cancel
+	rp.resultsChan = make(chan shpanstream.Result[O])
+	rp.dispatchDone = make(chan struct{})
+	rp.completed = false
+	go rp.dispatch(internalCtx)
+	return nil
+}
+
+// dispatch reads the groups, and reduces each one on its own goroutine, once one of the concurrency slots is free.
+// Groups keep being read while waiting for a slot, so a new group never blocks the groups being reduced.
+func (rp *groupByReduceProvider[T, K, O]) dispatch(ctx context.Context) {
+	defer close(rp.dispatchDone)
+
+	var sem chan struct{}
+	if rp.concurrency > 0 {
+		sem = make(chan struct{}, rp.concurrency)
+	}
+	send := func(r shpanstream.Result[O]) {
+		select {
+		case rp.resultsChan <- r:
+		case <-ctx.Done():
+		}
+	}
+
+	var wg sync.WaitGroup
+	var groupedErr error
+	for {
+		// A panic in the source fails the stream as the source error
+		e, err := callWithPanicGuard(func() (shpanstream.Entry[K, Stream[T]], error) {
+			return rp.grouped.provider(ctx)
+		})
+		if err != nil {
+			if err != io.EOF {
+				groupedErr = err
+			}
+			break
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Make sure the group is detached even if the reducer did not consume it
+			defer doCloseSubStream(e.Value)
+			if sem != nil {
+				select {
+				case sem <- struct{}{}:
+				case <-ctx.Done():
+					return
+				}
+				defer func() { <-sem }()
+			}
+
+			o, err := callWithPanicGuard(func() (O, error) {
+				return rp.reducer(ctx, e.Key, e.Value)
+			})
+			if err != nil {
+				// Wrapping the error so even if it is io.EOF, it is not mistaken for end of Stream
+				send(shpanstream.Result[O]{Err: fmt.Errorf("failed reducing group %v: %w", e.Key, err)})
+				return
+			}
+			send(shpanstream.Result[O]{Value: o})
+		}()
+	}
+	wg.Wait()
+	if groupedErr != nil {
+		send(shpanstream.Result[O]{Err: groupedErr})
+	}
+	rp.completed = groupedErr == nil
+	close(rp.resultsChan)
+}
+
+func (rp *groupByReduceProvider[T, K, O]) emit(ctx context.Context) (O, error) {
+	select {
+	case <-ctx.Done():
+		return util.DefaultValue[O](), ctx.Err()
+	case r, stillGood := <-rp.resultsChan:
+		if !stillGood {
+			// completed was set before closing the channel
+			if rp.completed {
+				return util.DefaultValue[O](), io.EOF
+			}
+			if ctx.Err() != nil {
+				return util.DefaultValue[O](), ctx.Err()
+			}
+			// Should never happen
+			return util.DefaultValue[O](), fmt.Errorf("group by reduce stream channel closed prematurely")
+		}
+		return r.Unpack()
+	}
+}
+
+func (rp *groupByReduceProvider[T, K, O]) close() {
+	// Stop the dispatcher and reducers and join them BEFORE closing the grouped stream, so nothing drives
+	// the grouped stream concurrently with its Close
+	if rp.internalCancel != nil {
+		rp.internalCancel()
+		<-rp.dispatchDone
+		rp.internalCancel = nil
+	}
+	doCloseSubStream(rp.grouped)
+	if rp.groupedCancel != nil {
+		rp.groupedCancel()
+		rp.groupedCancel = nil
+	}
+}
@@ -0,0 +1,253 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/shpandrak/shpanstream"
+	"github.com/stretchr/testify/require"
+	"slices"
+	"sync"
+	"testing"
+)
+
+type groupByTestReading struct {
+	device string
+	value  int
+}
+
+func groupByTestReadings(n int, devices ...string) []groupByTestReading {
+	ret := make([]groupByTestReading, 0, n)
+	for i := 0; i < n; i++ {
+		ret = append(ret, groupByTestReading{device: devices[i%len(devices)], value: i})
+	}
+	return ret
+}
+
+func deviceOf(r groupByTestReading) string {
+	return r.device
+}
+
+func TestGroupBy(t *testing.T) {
+	readings := groupByTestReadings(1000, "a", "b", "c")
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	results := map[string][]int{}
+	err := GroupBy(FromSlice(readings), deviceOf, WithGroupByBufferSizeOption(2)).
+		Consume(context.Background(), func(e shpanstream.Entry[string, Stream[groupByTestReading]]) {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				values, err := Map(e.Value, func(r groupByTestReading) int { return r.value }).Collect(context.Background())
+				require.NoError(t, err)
+				mu.Lock()
+				defer mu.Unlock()
+				results[e.Key] = values
+			}()
+		})
+	wg.Wait()
+	require.NoError(t, err)
+
+	require.Len(t, results, 3)
+	for i, device := range []string{"a", "b", "c"} {
+		values := results[device]
+		require.Len(t, values, (1000-i+2)/3)
+		for j, v := range values {
+			// Source order is kept within the group
+			require.Equal(t, j*3+i, v)
+		}
+	}
+}
+
+func sumGroupValues(ctx context.Context, key string, group Stream[groupByTestReading]) (string, error) {
+	count, err := group.Count(ctx)
+	return fmt.Sprintf("%s:%d", key, count), err
+}
+
+func TestGroupByReduce(t *testing.T) {
+	s := GroupByReduce(FromSlice(groupByTestReadings(100, "a", "b", "c", "d")), deviceOf, sumGroupValues)
+
+	res := s.MustCollect()
+	slices.Sort(res)
+	require.Equal(t, []string{"a:25", "b:25", "c:25", "d:25"}, res)
+
+	// Re-consumable
+	res = s.MustCollect()
+	slices.Sort(res)
+	require.Equal(t, []string{"a:25", "b:25", "c:25", "d:25"}, res)
+
+	// Fewer reducers than keys still reduce each key once, the waiting groups keep their elements
+	res = GroupByReduce(
+		FromSlice(groupByTestReadings(6, "a", "b")),
+		deviceOf,
+		sumGroupValues,
+		WithGroupByConcurrencyOption(1),
+		WithGroupByBufferSizeOption(1),
+	).MustCollect()
+	slices.Sort(res)
+	require.Equal(t, []string{"a:3", "b:3"}, res)
+
+	res = GroupByReduce(
+		FromSlice(groupByTestReadings(1000, "a", "b", "c", "d", "e")),
+		deviceOf,
+		sumGroupValues,
+		WithGroupByConcurrencyOption(2),
+		WithGroupByBufferSizeOption(1),
+	).MustCollect()
+	slices.Sort(res)
+	require.Equal(t, []string{"a:200", "b:200", "c:200", "d:200", "e:200"}, res)
+}
+
+func TestGroupByReduce_Concurrency(t *testing.T) {
+	res := GroupByReduce(
+		FromSlice(groupByTestReadings(100, "a", "b", "c", "d")),
+		deviceOf,
+		sumGroupValues,
+		WithGroupByConcurrencyOption(4),
+		WithGroupByBufferSizeOption(1),
+	).MustCollect()
+	slices.Sort(res)
+	require.Equal(t, []string{"a:25", "b:25", "c:25", "d:25"}, res)
+
+	// Fewer reducers than keys still reduce each key once, the waiting groups keep their elements
+	res = GroupByReduce(
+		FromSlice(groupByTestReadings(6, "a", "b")),
+		deviceOf,
+		sumGroupValues,
+		WithGroupByConcurrencyOption(1),
+		WithGroupByBufferSizeOption(1),
+	).MustCollect()
+	slices.Sort(res)
+	require.Equal(t, []string{"a:3", "b:3"}, res)
+
+	res = GroupByReduce(
+		FromSlice(groupByTestReadings(1000, "a", "b", "c", "d", "e")),
+		deviceOf,
+		sumGroupValues,
+		WithGroupByConcurrencyOption(2),
+		WithGroupByBufferSizeOption(1),
+	).MustCollect()
+	slices.Sort(res)
+	require.Equal(t, []string{"a:200", "b:200", "c:200", "d:200", "e:200"}, res)
+}
+
+func TestGroupByReduce_EvictsLeastRecentlyActiveGroup(t *testing.T) {
+	src := Just(
+		groupByTestReading{device: "a"},
+		groupByTestReading{device: "a"},
+		groupByTestReading{device: "b"},
+		groupByTestReading{device: "a"},
+	)
+	res := GroupByReduce(src, deviceOf, sumGroupValues, WithGroupByMaxOpenGroupsOption(1)).MustCollect()
+	slices.Sort(res)
+	require.Equal(t, []string{"a:1", "a:2", "b:1"}, res)
+
+	src = Just(
+		groupByTestReading{device: "a"},
+		groupByTestReading{device: "b"},
+		groupByTestReading{device: "a"},
+		groupByTestReading{device: "c"},
+		groupByTestReading{device: "b"},
+	)
+	res = GroupByReduce(src, deviceOf, sumGroupValues, WithGroupByMaxOpenGroupsOption(2)).MustCollect()
+	slices.Sort(res)
+	// b is the least recently active when c arrives
+	require.Equal(t, []string{"a:2", "b:1", "b:1", "c:1"}, res)
+}
+
+func TestGroupByReduce_ReducerNotConsumingGroup(t *testing.T) {
+	res := GroupByReduce(
+		FromSlice(groupByTestReadings(1000, "a", "b")),
+		deviceOf,
+		func(ctx context.Context, key string, group Stream[groupByTestReading]) (string, error) {
+			return key, nil
+		},
+		WithGroupByBufferSizeOption(1),
+	).MustCollect()
+	slices.Sort(res)
+	require.Equal(t, []string{"a", "b"}, res)
+}
+
+func TestGroupByReduce_Errors(t *testing.T) {
+	boom := errors.New("boom")
+	_, err := GroupByReduce(
+		FromSlice(groupByTestReadings(100, "a", "b")),
+		deviceOf,
+		func(ctx context.Context, key string, group Stream[groupByTestReading]) (string, error) {
+			if key == "b" {
+				return "", boom
+			}
+			return sumGroupValues(ctx, key, group)
+		},
+	).Collect(context.Background())
+	require.ErrorIs(t, err, boom)
+
+	_, err = GroupByReduce(
+		MapWithErr(FromSlice(makeRange(100)), func(v int) (groupByTestReading, error) {
+			if v == 50 {
+				return groupByTestReading{}, boom
+			}
+			return groupByTestReading{device: fmt.Sprint(v % 3)}, nil
+		}),
+		deviceOf,
+		sumGroupValues,
+	).Collect(context.Background())
+	require.ErrorIs(t, err, boom)
+
+	_, err = GroupByReduce(FromSlice(groupByTestReadings(10, "a")), deviceOf, sumGroupValues, WithGroupByBufferSizeOption(0)).
+		Collect(context.Background())
+	require.Error(t, err)
+}
+
+func TestGroupByReduce_EarlyTermination(t *testing.T) {
+	i := 0
+	infinite := NewSimpleStream(func(ctx context.Context) (groupByTestReading, error) {
+		i++
+		return groupByTestReading{device: fmt.Sprint(i % 2)}, nil
+	})
+	// Evicting the groups completes them, while the source never ends
+	res := GroupByReduce(infinite, deviceOf, sumGroupValues, WithGroupByMaxOpenGroupsOption(1)).Limit(3).MustCollect()
+	require.Len(t, res, 3)
+	for _, r := range res {
+		require.Contains(t, []string{"0:1", "1:1"}, r)
+	}
+}
+
+func TestGroupBy_GroupIsSingleUse(t *testing.T) {
+	groups := GroupBy(Just(1, 2, 3), func(v int) int { return v % 2 })
+	err := groups.Consume(context.Background(), func(e shpanstream.Entry[int, Stream[int]]) {
+		// Groups are closed without being consumed, their elements are dropped
+		doCloseSubStream(e.Value)
+	})
+	require.NoError(t, err)
+
+	_, err = GroupByReduce(Just(1), func(v int) int { return v }, func(ctx context.Context, key int, group Stream[int]) (int, error) {
+		if _, err := group.Collect(ctx); err != nil {
+			return 0, err
+		}
+		return group.Count(ctx)
+	}).Collect(context.Background())
+	require.Error(t, err)
+}
+
+func TestGroupByReduce_Panics(t *testing.T) {
+	_, err := GroupByReduce(FromSlice(makeRange(20)), func(v int) int { return v % 3 }, func(ctx context.Context, key int, group Stream[int]) (int, error) {
+		if key == 1 {
+			panic("reducer boom")
+		}
+		return group.Count(ctx)
+	}).Collect(context.Background())
+	requirePanicError(t, err, "reducer boom")
+
+	// A panic in the key function is recovered on the goroutine reading the source
+	_, err = GroupByReduce(FromSlice(makeRange(20)), func(v int) int {
+		if v == 10 {
+			panic("key boom")
+		}
+		return v % 3
+	}, func(ctx context.Context, key int, group Stream[int]) (int, error) {
+		return group.Count(ctx)
+	}).Collect(context.Background())
+	requirePanicError(t, err, "key boom")
+}
@@ -0,0 +1,72 @@
+package stream
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// makeRange returns the ints 0..n-1.
+func makeRange(n int) []int {
+	ret := make([]int, n)
+	for i := range ret {
+		ret[i] = i
+	}
+	return ret
+}
+
+// clockTestStart is the start time of the ManualClock of the time based operator tests.
+var clockTestStart = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// collectAsync collects the stream on a background goroutine.
+func collectAsync[T any](s Stream[T]) <-chan []T {
+	ret := make(chan []T, 1)
+	go func() {
+		defer close(ret)
+		res, err := s.Collect(context.Background())
+		if err == nil {
+			ret <- res
+		}
+	}()
+	return ret
+}
+
+// steppedSource is a source whose elements are pushed by the test, where push returns only once the element
+// was delivered downstream (i.e. the next element was requested).
+type steppedSource struct {
+	ch     chan int
+	pulled chan struct{}
+}
+
+func newSteppedSource() (*steppedSource, Stream[int]) {
+	ss := &steppedSource{ch: make(chan int), pulled: make(chan struct{})}
+	first := true
+	return ss, NewSimpleStream(func(ctx context.Context) (int, error) {
+		if !first {
+			select {
+			case ss.pulled <- struct{}{}:
+			case <-ctx.Done():
+				return 0, ctx.Err()
+			}
+		}
+		first = false
+		select {
+		case v, ok := <-ss.ch:
+			if !ok {
+				return 0, io.EOF
+			}
+			return v, nil
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+	})
+}
+
+func (ss *steppedSource) push(v int) {
+	ss.ch <- v
+	<-ss.pulled
+}
+
+func (ss *steppedSource) end() {
+	close(ss.ch)
+}
@@ -0,0 +1,50 @@
+package stream
+
+import (
+	"context"
+	"fmt"
+	"github.com/shpandrak/shpanstream/internal/util"
+	"io"
+)
+
+// FromReader creates a stream of the elements decoded from the reader using the codec (e.g. JsonCodec for
+// newline delimited json, or GobCodec). The stream ends when the decoder returns io.EOF.
+// The reader is not closed by the stream, and since it is consumed as the stream is consumed, the stream can be
+// consumed only once (unless the reader is re-positioned in between).
+func FromReader[T any](r io.Reader, codec Codec[T]) Stream[T] {
+	var dec Decoder[T]
+	return NewSimpleStream(
+		func(ctx context.Context) (T, error) {
+			if ctx.Err() != nil {
+				return util.DefaultValue[T](), ctx.Err()
+			}
+			v, err := dec.Decode()
+			if err != nil {
+				if err == io.EOF {
+					return util.DefaultValue[T](), io.EOF
+				}
+				return util.DefaultValue[T](), fmt.Errorf("failed decoding stream element: %w", err)
+			}
+			return v, nil
+		},
+		WithOpenFuncOption(func(_ context.Context) error {
+			dec = codec.NewDecoder(r)
+			return nil
+		}),
+	)
+}
+
+// EncodeTo consumes the stream, writing its elements to the writer using the codec, the counterpart of
+// FromReader. The elements are written as a sequence of records of the codec (e.g. newline delimited json using
+// JsonCodec) with no enclosing framing, so a json array is still written using jsonstream.StreamJsonToWriter.
+// The writer is neither buffered nor closed by EncodeTo.
+// It is not named WriteTo, since a WriteTo method is expected to implement io.WriterTo.
+func (s Stream[T]) EncodeTo(ctx context.Context, w io.Writer, codec Codec[T]) error {
+	enc := codec.NewEncoder(w)
+	return s.ConsumeWithErr(ctx, func(v T) error {
+		if err := enc.Encode(v); err != nil {
+			return fmt.Errorf("failed encoding stream element: %w", err)
+		}
+		return nil
+	})
+}
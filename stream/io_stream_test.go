@@ -0,0 +1,59 @@
+package stream
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+type ioTestEvent struct {
+	Id   int
+	Name string
+}
+
+func TestFromReader_EncodeTo_RoundTrip(t *testing.T) {
+	events := []ioTestEvent{{1, "one"}, {2, "two"}, {3, "three"}}
+	for name, codec := range map[string]Codec[ioTestEvent]{
+		"json": JsonCodec[ioTestEvent](),
+		"gob":  GobCodec[ioTestEvent](),
+	} {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, FromSlice(events).EncodeTo(context.Background(), &buf, codec))
+			require.Equal(t, events, FromReader(&buf, codec).MustCollect())
+		})
+	}
+}
+
+func TestFromReader(t *testing.T) {
+	s := FromReader(strings.NewReader("{\"Id\":1,\"Name\":\"one\"}\n{\"Id\":2,\"Name\":\"two\"}\n"), JsonCodec[ioTestEvent]())
+	require.Equal(t, []ioTestEvent{{1, "one"}, {2, "two"}}, s.MustCollect())
+
+	require.Empty(t, FromReader(strings.NewReader(""), JsonCodec[ioTestEvent]()).MustCollect())
+
+	_, err := FromReader(strings.NewReader("{\"Id\":1}\nnot json\n"), JsonCodec[ioTestEvent]()).
+		Collect(context.Background())
+	require.ErrorContains(t, err, "failed decoding stream element")
+}
+
+type failingWriter struct {
+	err error
+}
+
+func (fw failingWriter) Write(_ []byte) (int, error) {
+	return 0, fw.err
+}
+
+func TestEncodeTo_Error(t *testing.T) {
+	boom := errors.New("boom")
+	err := Just(1, 2).EncodeTo(context.Background(), failingWriter{err: boom}, JsonCodec[int]())
+	require.ErrorIs(t, err, boom)
+
+	var buf bytes.Buffer
+	err = errAt(FromSlice(makeRange(5)), 3, boom).EncodeTo(context.Background(), &buf, JsonCodec[int]())
+	require.ErrorIs(t, err, boom)
+	require.Equal(t, "0\n1\n2\n", buf.String())
+}
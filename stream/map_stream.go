@@ -27,6 +27,24 @@ func (c *concurrentMapOption) mapStreamOptionName() string {
 	return "concurrent"
 }
 
+type orderedConcurrentMapOption struct {
+	concurrency int
+	maxInFlight int
+}
+
+// WithOrderedConcurrentMapOption is causing the mapper function to be called concurrently in separate goroutines,
+// like WithConcurrentMapOption, while emitting the results in the source stream order. This is useful for mapping
+// sorted streams (e.g. time sorted streams) that are later merged, joined or aligned.
+// maxInFlight bounds the number of elements that are read from the source but not yet emitted (the reorder
+// buffer), so a slow mapper invocation holds back at most maxInFlight elements. It must be at least concurrency.
+func WithOrderedConcurrentMapOption(concurrency int, maxInFlight int) MapOption {
+	return &orderedConcurrentMapOption{concurrency: concurrency, maxInFlight: maxInFlight}
+}
+
+func (c *orderedConcurrentMapOption) mapStreamOptionName() string {
+	return "orderedConcurrent"
+}
+
 // Map maps the source stream to a target stream using the provided mapper function.
 func Map[SRC any, TGT any](
 	src Stream[SRC],
@@ -56,6 +74,8 @@ func MapWithErrAndCtx[SRC any, TGT any](
 			switch cOpt := opt.(type) {
 			case *concurrentMapOption:
 				return mapStreamConcurrently[SRC, TGT](src, cOpt.concurrency, mapper)
+			case *orderedConcurrentMapOption:
+				return mapStreamConcurrentlyOrdered[SRC, TGT](src, cOpt.concurrency, cOpt.maxInFlight, mapper)
 			default:
 				return Error[TGT](fmt.Errorf("unsupported map stream option type: %T", opt))
 			}
@@ -0,0 +1,150 @@
+package stream
+
+import (
+	"context"
+	"fmt"
+	"github.com/shpandrak/shpanstream"
+	"github.com/shpandrak/shpanstream/internal/util"
+	"io"
+	"sync"
+)
+
+// Merge merges multiple streams into a single stream, emitting the elements in the order they arrive.
+// Each stream is consumed on its own goroutine, so this is suited for combining live (possibly infinite)
+// sources such as channels. For sorted streams, see MergeSortedStreams, and for sequential streams, ConcatStreams.
+// An error (or a panic) from any of the streams fails the merged stream and cancels the others.
+func Merge[T any](streams ...Stream[T]) Stream[T] {
+	if len(streams) == 0 {
+		return Empty[T]()
+	}
+	if len(streams) == 1 {
+		return streams[0]
+	}
+	mp := &mergeStreamProvider[T]{streams: streams}
+	// Same as the concurrent mapper, the provider owns the sources lifecycle so that close can join the
+	// background goroutines BEFORE closing the sources.
+	return NewSimpleStream(mp.emit, WithOpenFuncOption(mp.open), WithCloseFuncOption(mp.close))
+}
+
+type mergeStreamProvider[T any] struct {
+	streams []Stream[T]
+
+	// Per-consumption state, (re)initialised in open and torn down in close.
+	resultsChan    chan shpanstream.Result[T]
+	eofCtx         context.Context
+	eofCancel      context.CancelFunc
+	internalCancel context.CancelFunc
+	srcCancels     []context.CancelFunc
+	producerDone   chan struct{}
+}
+
+func (mp *mergeStreamProvider[T]) open(ctx context.Context) error {
+	mp.srcCancels = make([]context.CancelFunc, 0, len(mp.streams))
+	for _, s := range mp.streams {
+		srcCancel, err := doOpenStream[T](ctx, s)
+		if err != nil {
+			// Close the streams opened so far, close is not called when open fails
+			mp.closeSources()
+			return err
+		}
+		mp.srcCancels = append(mp.srcCancels, srcCancel)
+	}
+
+	mp.resultsChan = make(chan shpanstream.Result[T], len(mp.streams))
+
+	// internalCtx bounds the reader goroutines; close (or an error from any stream) cancels it.
+	internalCtx, internalCancel := context.WithCancel(ctx)
+	mp.internalCancel = internalCancel
+
+	// eofCtx lets emit distinguish "closed because all the streams reached EOF" from "closed on cancel".
+	eofCtx, eofCancelFunc := context.WithCancel(context.Background())
+	mp.eofCtx = eofCtx
+	mp.eofCancel = eofCancelFunc
+
+	// Closed only after every reader has fully exited, so close can join on it.
+	mp.producerDone = make(chan struct{})
+
+	var wg sync.WaitGroup
+	for _, s := range mp.streams {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				// A panic in the stream fails the merged stream as its error
+				v, err := callWithPanicGuard(func() (T, error) {
+					return s.provider(internalCtx)
+				})
+				if err != nil {
+					if err == io.EOF {
+						return
+					}
+					select {
+					case mp.resultsChan <- shpanstream.Result[T]{Err: err}:
+					case <-internalCtx.Done():
+					}
+					// Stop the other streams, the merged stream fails on the first error
+					internalCancel()
+					return
+				}
+				select {
+				case mp.resultsChan <- shpanstream.Result[T]{Value: v}:
+				case <-internalCtx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		defer close(mp.producerDone)
+		wg.Wait()
+		// internalCtx is cancelled on error, and on early termination
+		if internalCtx.Err() == nil {
+			eofCancelFunc()
+		}
+		close(mp.resultsChan)
+	}()
+	return nil
+}
+
+func (mp *mergeStreamProvider[T]) emit(ctx context.Context) (T, error) {
+	select {
+	case <-ctx.Done():
+		return util.DefaultValue[T](), ctx.Err()
+	case r, stillGood := <-mp.resultsChan:
+		if !stillGood {
+			if mp.eofCtx.Err() != nil {
+				return util.DefaultValue[T](), io.EOF
+			}
+			if ctx.Err() != nil {
+				return util.DefaultValue[T](), ctx.Err()
+			}
+			// Should never happen
+			return util.DefaultValue[T](), fmt.Errorf("merge stream channel closed prematurely")
+		}
+		return r.Unpack()
+	}
+}
+
+func (mp *mergeStreamProvider[T]) close() {
+	// Stop the readers and wait for them to fully exit BEFORE closing the sources, so nothing drives a
+	// source's provider concurrently with its Close.
+	if mp.internalCancel != nil {
+		mp.internalCancel()
+	}
+	if mp.producerDone != nil {
+		<-mp.producerDone
+	}
+	mp.closeSources()
+	if mp.eofCancel != nil {
+		mp.eofCancel()
+	}
+}
+
+// closeSources closes the streams that were opened, each exactly once.
+func (mp *mergeStreamProvider[T]) closeSources() {
+	for i, srcCancel := range mp.srcCancels {
+		doCloseSubStream[T](mp.streams[i])
+		srcCancel()
+	}
+	mp.srcCancels = nil
+}
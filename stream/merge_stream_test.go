@@ -0,0 +1,118 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/require"
+	"slices"
+	"sync/atomic"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	s := Merge(
+		Just(1, 4, 7),
+		Just(2, 5, 8, 9),
+		Empty[int](),
+		Just(3, 6),
+	)
+	for range 2 {
+		res := s.MustCollect()
+		slices.Sort(res)
+		require.Equal(t, []int{1, 2, 3, 4, 5, 6, 7, 8, 9}, res)
+	}
+	require.Len(t, Merge[int]().MustCollect(), 0)
+	require.Equal(t, []int{1, 2}, Merge(Just(1, 2)).MustCollect())
+}
+
+func TestMerge_InfiniteChannels(t *testing.T) {
+	done := make(chan struct{})
+	defer close(done)
+	feed := func(v int) <-chan int {
+		ch := make(chan int)
+		go func() {
+			for {
+				select {
+				case ch <- v:
+				case <-done:
+					return
+				}
+			}
+		}()
+		return ch
+	}
+
+	// Elements of both channels arrive as they come, Limit terminates the merged stream
+	res := Merge(FromChannel(feed(1)), FromChannel(feed(2))).
+		Filter(func(v int) bool { return v == 2 }).
+		Limit(10).
+		MustCollect()
+	require.Len(t, res, 10)
+}
+
+func TestMerge_ErrorCancelsOthers(t *testing.T) {
+	boom := errors.New("boom")
+	var opened, closed atomic.Int32
+	tracked := func(s Stream[int]) Stream[int] {
+		return s.WithAdditionalLifecycle(NewLifecycle(
+			func(ctx context.Context) error {
+				opened.Add(1)
+				return nil
+			},
+			func() {
+				closed.Add(1)
+			},
+		))
+	}
+
+	// The infinite stream is only stopped by the cancellation
+	infinite := tracked(NewSimpleStream(func(ctx context.Context) (int, error) {
+		<-ctx.Done()
+		return 0, ctx.Err()
+	}))
+	_, err := Merge(infinite, tracked(errAt(FromSlice(makeRange(10)), 5, boom))).Collect(context.Background())
+	require.ErrorIs(t, err, boom)
+	require.EqualValues(t, 2, opened.Load())
+	require.EqualValues(t, 2, closed.Load())
+}
+
+func TestMerge_PanicCancelsOthers(t *testing.T) {
+	infinite := NewSimpleStream(func(ctx context.Context) (int, error) {
+		<-ctx.Done()
+		return 0, ctx.Err()
+	})
+	panicking := Map(FromSlice(makeRange(10)), func(v int) int {
+		if v == 5 {
+			panic("boom")
+		}
+		return v
+	})
+	_, err := Merge(infinite, panicking).Collect(context.Background())
+	requirePanicError(t, err, "boom")
+}
+
+func TestMerge_OpenFailureClosesOpened(t *testing.T) {
+	boom := errors.New("boom")
+	var closed atomic.Int32
+	ok := Just(1).WithAdditionalLifecycle(NewLifecycle(nil, func() { closed.Add(1) }))
+	_, err := Merge(ok, Error[int](boom)).Collect(context.Background())
+	require.ErrorIs(t, err, boom)
+	require.EqualValues(t, 1, closed.Load())
+}
+
+func TestMerge_ContextCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	infinite := func() Stream[int] {
+		return NewSimpleStream(func(ctx context.Context) (int, error) {
+			return 1, nil
+		})
+	}
+	var count int
+	err := Merge(infinite(), infinite(), infinite()).Consume(ctx, func(int) {
+		count++
+		if count == 100 {
+			cancel()
+		}
+	})
+	require.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,211 @@
+package stream
+
+import (
+	"context"
+	"fmt"
+	"github.com/shpandrak/shpanstream"
+	"github.com/shpandrak/shpanstream/internal/util"
+	"io"
+	"sync"
+)
+
+// orderedConcurrentSlot is the future result of mapping a single source element. Slots are queued in source
+// order, and each one is completed by whichever worker maps its element.
+type orderedConcurrentSlot[TGT any] chan shpanstream.Result[TGT]
+
+type orderedConcurrentJob[SRC any, TGT any] struct {
+	v    SRC
+	slot orderedConcurrentSlot[TGT]
+}
+
+type orderedConcurrentStreamMapperProvider[SRC any, TGT any] struct {
+	concurrency int
+	maxInFlight int
+	mapper      func(context.Context, SRC) (TGT, error)
+	src         Stream[SRC]
+
+	// Per-consumption state, (re)initialised in open and torn down in close.
+	slotsChan      chan orderedConcurrentSlot[TGT]
+	inFlight       chan struct{}
+	eofCtx         context.Context
+	eofCancel      context.CancelFunc
+	internalCancel context.CancelFunc
+	srcCancel      context.CancelFunc
+	producerDone   chan struct{}
+}
+
+// mapStreamConcurrentlyOrdered returns a mapped stream using a mapper function. The mapping is done
+// concurrently, while the resulting stream order matches the source order.
+func mapStreamConcurrentlyOrdered[SRC any, TGT any](
+	src Stream[SRC],
+	concurrency int,
+	maxInFlight int,
+	mapper shpanstream.MapperWithErrAndCtx[SRC, TGT],
+) Stream[TGT] {
+	if concurrency <= 0 {
+		return Error[TGT](fmt.Errorf("concurrency must be > 0"))
+	}
+	if maxInFlight < concurrency {
+		return Error[TGT](fmt.Errorf("max in flight must be >= concurrency"))
+	}
+	c := &orderedConcurrentStreamMapperProvider[SRC, TGT]{
+		concurrency: concurrency,
+		maxInFlight: maxInFlight,
+		mapper:      mapper,
+		src:         src,
+	}
+	// Same as the unordered concurrent mapper, the provider owns the source lifecycle so that close can join
+	// the background goroutines BEFORE closing the source.
+	return NewSimpleStream(
+		c.emit,
+		WithOpenFuncOption(c.open),
+		WithCloseFuncOption(c.close),
+	)
+}
+
+func (c *orderedConcurrentStreamMapperProvider[SRC, TGT]) open(ctx context.Context) error {
+	srcCancel, err := doOpenStream[SRC](ctx, c.src)
+	if err != nil {
+		return err
+	}
+	c.srcCancel = srcCancel
+	srcProviderFunc := c.src.provider
+
+	// inFlight bounds the reorder buffer: a token is taken before reading an element from the source, and
+	// returned once the element is emitted, so the reader blocks once maxInFlight elements are pending.
+	c.inFlight = make(chan struct{}, c.maxInFlight)
+	c.slotsChan = make(chan orderedConcurrentSlot[TGT], c.maxInFlight)
+	jobsChan := make(chan orderedConcurrentJob[SRC, TGT], c.concurrency)
+
+	// internalCtx bounds the reader + worker goroutines; close cancels it and waits for them.
+	internalCtx, internalCancel := context.WithCancel(ctx)
+	c.internalCancel = internalCancel
+
+	// eofCtx lets emit distinguish "closed because the source reached EOF" from "closed on cancel".
+	eofCtx, eofCancelFunc := context.WithCancel(context.Background())
+	c.eofCtx = eofCtx
+	c.eofCancel = eofCancelFunc
+
+	// Closed only after the reader and every worker have fully exited, so close can join on it.
+	c.producerDone = make(chan struct{})
+
+	// Start the workers. Slots are buffered (size 1), so completing a slot never blocks.
+	var wg sync.WaitGroup
+	for i := 0; i < c.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-internalCtx.Done():
+					return
+				case job, stillGood := <-jobsChan:
+					if !stillGood {
+						return
+					}
+					// A panic fails the stream as the mapper error
+					tgt, err := callWithPanicGuard(func() (TGT, error) {
+						return c.mapper(internalCtx, job.v)
+					})
+					job.slot <- shpanstream.Result[TGT]{Value: tgt, Err: err}
+				}
+			}
+		}()
+	}
+
+	// Start the reader.
+	go func() {
+		defer close(c.producerDone)
+		defer func() {
+			close(jobsChan)
+			wg.Wait()
+			// Closing the slots only after the workers exited; already queued slots are still emitted.
+			close(c.slotsChan)
+		}()
+		for {
+			select {
+			case c.inFlight <- struct{}{}:
+			case <-internalCtx.Done():
+				return
+			}
+			// A panic upstream fails the stream as the source error
+			v, err := callWithPanicGuard(func() (SRC, error) {
+				return srcProviderFunc(internalCtx)
+			})
+			if err != nil {
+				if err == io.EOF {
+					eofCancelFunc()
+					return
+				}
+				// Queue the error in order, after the elements read so far.
+				slot := make(orderedConcurrentSlot[TGT], 1)
+				slot <- shpanstream.Result[TGT]{Err: err}
+				select {
+				case c.slotsChan <- slot:
+				case <-internalCtx.Done():
+				}
+				return
+			}
+
+			// Queue the slot first, so the order is kept. This never blocks, since the slots are bounded by inFlight.
+			slot := make(orderedConcurrentSlot[TGT], 1)
+			c.slotsChan <- slot
+			select {
+			case jobsChan <- orderedConcurrentJob[SRC, TGT]{v: v, slot: slot}:
+			case <-internalCtx.Done():
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (c *orderedConcurrentStreamMapperProvider[SRC, TGT]) emit(ctx context.Context) (TGT, error) {
+	var slot orderedConcurrentSlot[TGT]
+	select {
+	case <-ctx.Done():
+		return util.DefaultValue[TGT](), ctx.Err()
+	case s, stillGood := <-c.slotsChan:
+		if !stillGood {
+			if c.eofCtx.Err() != nil {
+				return util.DefaultValue[TGT](), io.EOF
+			}
+			if ctx.Err() != nil {
+				return util.DefaultValue[TGT](), ctx.Err()
+			}
+			// Should never happen
+			return util.DefaultValue[TGT](), fmt.Errorf("ordered concurrent stream channel closed prematurely")
+		}
+		slot = s
+	}
+
+	// Wait for the next element in order to be mapped.
+	select {
+	case <-ctx.Done():
+		return util.DefaultValue[TGT](), ctx.Err()
+	case r := <-slot:
+		<-c.inFlight
+		return r.Unpack()
+	}
+}
+
+func (c *orderedConcurrentStreamMapperProvider[SRC, TGT]) close() {
+	// Stop the reader + workers and wait for them to fully exit BEFORE closing the source, so
+	// nothing drives the source's provider concurrently with its Close.
+	if c.internalCancel != nil {
+		c.internalCancel()
+	}
+	if c.producerDone != nil {
+		<-c.producerDone
+	}
+	doCloseSubStream[SRC](c.src)
+	if c.srcCancel != nil {
+		c.srcCancel()
+	}
+	// On early termination the reader never reaches EOF and eofCancelFunc was never called;
+	// release the eofCtx here (calling it twice is safe).
+	if c.eofCancel != nil {
+		c.eofCancel()
+	}
+}
@@ -0,0 +1,161 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrderedConcurrentMap_KeepsSourceOrder(t *testing.T) {
+	s := Map(
+		FromSlice(makeRange(200)),
+		func(v int) int {
+			// Uneven mapping times, so results complete out of order
+			time.Sleep(time.Duration(rand.Intn(200)) * time.Microsecond)
+			return v * 10
+		},
+		WithOrderedConcurrentMapOption(8, 16),
+	)
+	expected := make([]int, 200)
+	for i := range expected {
+		expected[i] = i * 10
+	}
+	// Re-consumable
+	for i := 0; i < 3; i++ {
+		out, err := s.Collect(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, expected, out)
+	}
+}
+
+func TestOrderedConcurrentMap_BoundedInFlight(t *testing.T) {
+	var pulled atomic.Int32
+	release := make(chan struct{})
+	src := Map(FromSlice(makeRange(100)), func(v int) int {
+		pulled.Add(1)
+		return v
+	})
+	done := make(chan []int, 1)
+	go func() {
+		done <- Map(
+			src,
+			func(v int) int {
+				if v == 0 {
+					// The head of the line is slow, everything else piles up in the reorder buffer
+					<-release
+				}
+				return v
+			},
+			WithOrderedConcurrentMapOption(2, 4),
+		).MustCollect()
+	}()
+
+	require.Eventually(t, func() bool { return pulled.Load() >= 4 }, time.Second, time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+	require.EqualValues(t, 4, pulled.Load())
+
+	close(release)
+	require.Equal(t, makeRange(100), <-done)
+}
+
+func TestOrderedConcurrentMap_ErrorsInOrder(t *testing.T) {
+	boom := errors.New("boom")
+	var got []int
+	err := MapWithErr(
+		FromSlice(makeRange(100)),
+		func(v int) (int, error) {
+			if v == 50 {
+				return 0, boom
+			}
+			return v, nil
+		},
+		WithOrderedConcurrentMapOption(4, 8),
+	).Consume(context.Background(), func(v int) {
+		got = append(got, v)
+	})
+	require.ErrorIs(t, err, boom)
+	require.Equal(t, makeRange(50), got)
+
+	got = nil
+	err = Map(errAt(FromSlice(makeRange(100)), 30, boom), func(v int) int { return v }, WithOrderedConcurrentMapOption(4, 8)).
+		Consume(context.Background(), func(v int) {
+			got = append(got, v)
+		})
+	require.ErrorIs(t, err, boom)
+	require.Equal(t, makeRange(30), got)
+}
+
+func TestOrderedConcurrentMap_PanicsInOrder(t *testing.T) {
+	panicAt := func(bad int) func(v int) int {
+		return func(v int) int {
+			if v == bad {
+				panic("boom")
+			}
+			return v
+		}
+	}
+
+	// A panicking mapper, recovered on the worker goroutine
+	var got []int
+	err := Map(FromSlice(makeRange(100)), panicAt(50), WithOrderedConcurrentMapOption(4, 8)).
+		Consume(context.Background(), func(v int) {
+			got = append(got, v)
+		})
+	requirePanicError(t, err, "boom")
+	require.Equal(t, makeRange(50), got)
+
+	// A panic upstream, recovered on the reader goroutine
+	got = nil
+	err = Map(Map(FromSlice(makeRange(100)), panicAt(30)), func(v int) int { return v }, WithOrderedConcurrentMapOption(4, 8)).
+		Consume(context.Background(), func(v int) {
+			got = append(got, v)
+		})
+	requirePanicError(t, err, "boom")
+	require.Equal(t, makeRange(30), got)
+}
+
+func TestOrderedConcurrentMap_EarlyStopNoRace(t *testing.T) {
+	for i := 0; i < 300; i++ {
+		out, err := Map(
+			Just(1, 2, 3, 4, 5, 6, 7, 8, 9, 10),
+			func(v int) int { return v * 2 },
+			WithOrderedConcurrentMapOption(4, 4),
+		).
+			Limit(2).
+			Collect(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, []int{2, 4}, out)
+	}
+}
+
+func TestOrderedConcurrentMap_EarlyStopViaCtxCancelNoRace(t *testing.T) {
+	for i := 0; i < 300; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		got := 0
+		err := Map(
+			Just(1, 2, 3, 4, 5, 6, 7, 8, 9, 10),
+			func(v int) int { return v * 2 },
+			WithOrderedConcurrentMapOption(4, 8),
+		).
+			Consume(ctx, func(v int) {
+				got++
+				if got == 2 {
+					cancel()
+				}
+			})
+		require.ErrorIs(t, err, context.Canceled)
+		cancel()
+	}
+}
+
+func TestOrderedConcurrentMap_InvalidArguments(t *testing.T) {
+	_, err := Map(Just(1), func(v int) int { return v }, WithOrderedConcurrentMapOption(0, 1)).Collect(context.Background())
+	require.Error(t, err)
+	_, err = Map(Just(1), func(v int) int { return v }, WithOrderedConcurrentMapOption(4, 2)).Collect(context.Background())
+	require.Error(t, err)
+}
@@ -2,6 +2,7 @@ package stream
 
 import (
 	"context"
+	"github.com/shpandrak/shpanstream"
 	"github.com/shpandrak/shpanstream/internal/util"
 	"io"
 )
@@ -53,3 +54,85 @@ func (s Stream[T]) Page(pageNum int, pageSize int) Stream[T] {
 	skipped := pageNum * pageSize
 	return s.Skip(skipped).Limit(pageSize)
 }
+
+// TakeWhile emits elements as long as the predicate holds, and ends the stream on the first element the
+// predicate does not hold for (that element is not emitted). Upstream is not pulled after that, so infinite
+// sources are closed promptly, same as Limit.
+func (s Stream[T]) TakeWhile(predicate shpanstream.Predicate[T]) Stream[T] {
+	return s.takeWhile(predicate, false)
+}
+
+// TakeUntil emits elements until the predicate holds, and ends the stream right after emitting the first
+// element the predicate holds for (that element is emitted). Upstream is not pulled after that, see TakeWhile.
+func (s Stream[T]) TakeUntil(predicate shpanstream.Predicate[T]) Stream[T] {
+	return s.takeWhile(func(v T) bool {
+		return !predicate(v)
+	}, true)
+}
+
+// DropWhile skips elements as long as the predicate holds, and emits the rest of the stream starting with the
+// first element the predicate does not hold for.
+func (s Stream[T]) DropWhile(predicate shpanstream.Predicate[T]) Stream[T] {
+	skipping := true
+	return NewDownStreamSimple(
+		s,
+		func(ctx context.Context, srcProviderFunc ProviderFunc[T]) (T, error) {
+			for {
+				v, err := srcProviderFunc(ctx)
+				if err != nil {
+					return util.DefaultValue[T](), err
+				}
+				if !skipping || !predicate(v) {
+					skipping = false
+					return v, nil
+				}
+			}
+		},
+		func(_ context.Context, _ ProviderFunc[T]) error {
+			// Reset the state to support re-consumption (double collection)
+			skipping = true
+			return nil
+		},
+		nil,
+	)
+}
+
+// SkipUntil skips elements until the predicate holds, and emits the rest of the stream starting with the
+// first element the predicate holds for.
+func (s Stream[T]) SkipUntil(predicate shpanstream.Predicate[T]) Stream[T] {
+	return s.DropWhile(func(v T) bool {
+		return !predicate(v)
+	})
+}
+
+// takeWhile emits elements while the predicate holds. when inclusive, the first element the predicate does not
+// hold for is emitted as well, before ending the stream.
+func (s Stream[T]) takeWhile(predicate shpanstream.Predicate[T], inclusive bool) Stream[T] {
+	done := false
+	return NewDownStreamSimple(
+		s,
+		func(ctx context.Context, srcProviderFunc ProviderFunc[T]) (T, error) {
+			if done {
+				return util.DefaultValue[T](), io.EOF
+			}
+			v, err := srcProviderFunc(ctx)
+			if err != nil {
+				return util.DefaultValue[T](), err
+			}
+			if predicate(v) {
+				return v, nil
+			}
+			done = true
+			if inclusive {
+				return v, nil
+			}
+			return util.DefaultValue[T](), io.EOF
+		},
+		func(_ context.Context, _ ProviderFunc[T]) error {
+			// Reset the state to support re-consumption (double collection)
+			done = false
+			return nil
+		},
+		nil,
+	)
+}
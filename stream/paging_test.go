@@ -118,3 +118,59 @@ func TestStreamPage(t *testing.T) {
 		}
 	})
 }
+
+func TestStream_TakeWhile(t *testing.T) {
+	s := Just(1, 2, 3, 4, 1).TakeWhile(func(v int) bool { return v < 3 })
+	require.Equal(t, []int{1, 2}, s.MustCollect())
+	require.Equal(t, []int{1, 2}, s.MustCollect())
+
+	require.Empty(t, Just(5, 1).TakeWhile(func(v int) bool { return v < 3 }).MustCollect())
+	require.Equal(t, []int{1, 2}, Just(1, 2).TakeWhile(func(v int) bool { return v < 3 }).MustCollect())
+}
+
+func TestStream_TakeUntil(t *testing.T) {
+	s := Just(1, 2, 3, 4, 1).TakeUntil(func(v int) bool { return v >= 3 })
+	require.Equal(t, []int{1, 2, 3}, s.MustCollect())
+	require.Equal(t, []int{1, 2, 3}, s.MustCollect())
+}
+
+func TestStream_TakeWhile_InfiniteSourceIsClosedPromptly(t *testing.T) {
+	pulled := 0
+	closed := false
+	infinite := NewSimpleStream(
+		func(ctx context.Context) (int, error) {
+			pulled++
+			return pulled, nil
+		},
+		WithOpenFuncOption(func(ctx context.Context) error {
+			pulled = 0
+			closed = false
+			return nil
+		}),
+		WithCloseFuncOption(func() {
+			closed = true
+		}),
+	)
+
+	require.Equal(t, []int{1, 2, 3}, infinite.TakeWhile(func(v int) bool { return v <= 3 }).MustCollect())
+	require.Equal(t, 4, pulled)
+	require.True(t, closed)
+
+	require.Equal(t, []int{1, 2, 3}, infinite.TakeUntil(func(v int) bool { return v == 3 }).MustCollect())
+	require.Equal(t, 3, pulled)
+	require.True(t, closed)
+}
+
+func TestStream_DropWhile(t *testing.T) {
+	s := Just(1, 2, 3, 1, 4).DropWhile(func(v int) bool { return v < 3 })
+	require.Equal(t, []int{3, 1, 4}, s.MustCollect())
+	require.Equal(t, []int{3, 1, 4}, s.MustCollect())
+
+	require.Empty(t, Just(1, 2).DropWhile(func(v int) bool { return v < 3 }).MustCollect())
+}
+
+func TestStream_SkipUntil(t *testing.T) {
+	s := Just(1, 2, 3, 1, 4).SkipUntil(func(v int) bool { return v == 3 })
+	require.Equal(t, []int{3, 1, 4}, s.MustCollect())
+	require.Equal(t, []int{3, 1, 4}, s.MustCollect())
+}
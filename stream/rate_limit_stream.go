@@ -0,0 +1,289 @@
+package stream
+
+import (
+	"context"
+	"fmt"
+	"github.com/shpandrak/shpanstream/internal/util"
+	"io"
+	"time"
+)
+
+type TimeOption func(*timeConfig)
+
+type timeConfig struct {
+	clock Clock
+}
+
+// WithClockOption sets the clock used by time based operators, default is SystemClock.
+func WithClockOption(clock Clock) TimeOption {
+	return func(cfg *timeConfig) {
+		cfg.clock = clock
+	}
+}
+
+func newTimeConfig(opts []TimeOption) timeConfig {
+	cfg := timeConfig{
+		clock: SystemClock(),
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+// RateLimit limits the rate elements are emitted to eventsPerSecond, allowing bursts of up to burst elements,
+// using a token bucket. No elements are dropped, consumption is slowed down instead. When used before a
+// concurrent map (see WithConcurrentMapOption), it limits the rate of mapper invocations.
+func RateLimit[T any](s Stream[T], eventsPerSecond float64, burst int, opts ...TimeOption) Stream[T] {
+	if eventsPerSecond <= 0 {
+		return Error[T](fmt.Errorf("rate limit events per second must be greater than 0"))
+	}
+	if burst <= 0 {
+		return Error[T](fmt.Errorf("rate limit burst must be greater than 0"))
+	}
+	clock := newTimeConfig(opts).clock
+
+	var tokens float64
+	var last time.Time
+	return NewDownStreamSimple(
+		s,
+		func(ctx context.Context, srcProviderFunc ProviderFunc[T]) (T, error) {
+			v, err := srcProviderFunc(ctx)
+			if err != nil {
+				return util.DefaultValue[T](), err
+			}
+			for {
+				now := clock.Now()
+				tokens = min(float64(burst), tokens+now.Sub(last).Seconds()*eventsPerSecond)
+				last = now
+				if tokens >= 1 {
+					tokens--
+					break
+				}
+				wait := time.Duration((1 - tokens) / eventsPerSecond * float64(time.Second))
+				timer := clock.NewTimer(wait)
+				select {
+				case <-ctx.Done():
+					timer.Stop()
+					return util.DefaultValue[T](), ctx.Err()
+				case <-timer.C():
+				}
+			}
+			return v, nil
+		},
+		func(_ context.Context, _ ProviderFunc[T]) error {
+			// Reset the state to support re-consumption (double collection)
+			tokens = float64(burst)
+			last = clock.Now()
+			return nil
+		},
+		nil,
+	)
+}
+
+// Throttle emits an element, and then drops the elements arriving within the interval after it, emitting
+// at most one element per interval.
+func Throttle[T any](s Stream[T], interval time.Duration, opts ...TimeOption) Stream[T] {
+	if interval <= 0 {
+		return Error[T](fmt.Errorf("throttle interval must be greater than 0"))
+	}
+	clock := newTimeConfig(opts).clock
+
+	var lastEmitted *time.Time
+	return NewDownStreamSimple(
+		s,
+		func(ctx context.Context, srcProviderFunc ProviderFunc[T]) (T, error) {
+			for {
+				v, err := srcProviderFunc(ctx)
+				if err != nil {
+					return util.DefaultValue[T](), err
+				}
+				now := clock.Now()
+				if lastEmitted == nil || now.Sub(*lastEmitted) >= interval {
+					lastEmitted = &now
+					return v, nil
+				}
+			}
+		},
+		func(_ context.Context, _ ProviderFunc[T]) error {
+			// Reset the state to support re-consumption (double collection)
+			lastEmitted = nil
+			return nil
+		},
+		nil,
+	)
+}
+
+// Debounce emits an element only once no newer element arrived for the quiet period, dropping the elements
+// that were superseded. When the stream ends, the last pending element is emitted right away.
+// The source is read on a background goroutine, so elements are timed by their arrival.
+func Debounce[T any](s Stream[T], quiet time.Duration, opts ...TimeOption) Stream[T] {
+	if quiet <= 0 {
+		return Error[T](fmt.Errorf("debounce quiet period must be greater than 0"))
+	}
+	dp := &timedStreamProvider[T, T]{
+		src:   &bufferedStreamProvider[T]{src: s, size: 1},
+		clock: newTimeConfig(opts).clock,
+	}
+	dp.emitFunc = func(ctx context.Context) (T, error) {
+		var pending *T
+		var timer Timer
+		var timerChan <-chan time.Time
+		defer func() {
+			// Release the quiet period timer of the element still pending when returning
+			if timer != nil {
+				timer.Stop()
+			}
+		}()
+		for {
+			select {
+			case <-ctx.Done():
+				return util.DefaultValue[T](), ctx.Err()
+			case r, stillGood := <-dp.src.bufferChan:
+				if !stillGood {
+					if pending != nil {
+						dp.srcDone = true
+						return *pending, nil
+					}
+					return util.DefaultValue[T](), dp.closedErr(ctx)
+				}
+				if r.Err != nil {
+					return util.DefaultValue[T](), r.Err
+				}
+				pending = &r.Value
+				// The quiet period restarts, releasing the timer of the superseded element
+				if timer != nil {
+					timer.Stop()
+				}
+				timer = dp.clock.NewTimer(quiet)
+				timerChan = timer.C()
+			case <-timerChan:
+				return *pending, nil
+			}
+		}
+	}
+	return NewSimpleStream(dp.emit, WithOpenFuncOption(dp.open), WithCloseFuncOption(dp.close))
+}
+
+// Sample emits the latest element that arrived during each interval, skipping intervals with no new elements.
+// When the stream ends, the last pending element is emitted right away.
+// The source is read on a background goroutine, so elements are timed by their arrival.
+func Sample[T any](s Stream[T], interval time.Duration, opts ...TimeOption) Stream[T] {
+	if interval <= 0 {
+		return Error[T](fmt.Errorf("sample interval must be greater than 0"))
+	}
+	sp := &timedStreamProvider[T, T]{
+		src:   &bufferedStreamProvider[T]{src: s, size: 1},
+		clock: newTimeConfig(opts).clock,
+	}
+	// Intervals keep ticking across emit calls
+	var pending *T
+	var tick Timer
+	sp.openFunc = func() {
+		pending = nil
+		tick = sp.clock.NewTimer(interval)
+	}
+	sp.closeFunc = func() {
+		if tick != nil {
+			tick.Stop()
+		}
+	}
+	sp.emitFunc = func(ctx context.Context) (T, error) {
+		for {
+			select {
+			case <-ctx.Done():
+				return util.DefaultValue[T](), ctx.Err()
+			case r, stillGood := <-sp.src.bufferChan:
+				if !stillGood {
+					if pending != nil {
+						sp.srcDone = true
+						v := *pending
+						pending = nil
+						return v, nil
+					}
+					return util.DefaultValue[T](), sp.closedErr(ctx)
+				}
+				if r.Err != nil {
+					return util.DefaultValue[T](), r.Err
+				}
+				pending = &r.Value
+			case <-tick.C():
+				tick = sp.clock.NewTimer(interval)
+				if pending != nil {
+					v := *pending
+					pending = nil
+					return v, nil
+				}
+			}
+		}
+	}
+	return NewSimpleStream(sp.emit, WithOpenFuncOption(sp.open), WithCloseFuncOption(sp.close))
+}
+
+// timedStreamProvider reads the source on a background goroutine (using a bufferedStreamProvider), so emitFunc
+// can wait on the source and on the clock at the same time.
+type timedStreamProvider[T any, O any] struct {
+	src       *bufferedStreamProvider[T]
+	clock     Clock
+	openFunc  func()
+	emitFunc  func(ctx context.Context) (O, error)
+	closeFunc func()
+
+	// Per-consumption state, (re)initialised in open.
+	srcDone bool
+	// abandon is set by emitFunc when it gives up on a source that might be stuck (e.g. timed out), so close
+	// does not wait for the background goroutine.
+	abandon bool
+
+	// abandonedDone is closed once the goroutine of an abandoned consumption is done with the source.
+	abandonedDone <-chan struct{}
+}
+
+func (tp *timedStreamProvider[T, O]) open(ctx context.Context) error {
+	if tp.abandonedDone != nil {
+		// The source must not be consumed concurrently, wait for the abandoned consumption to let go of it
+		select {
+		case <-tp.abandonedDone:
+			tp.abandonedDone = nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	tp.srcDone = false
+	tp.abandon = false
+	if tp.openFunc != nil {
+		tp.openFunc()
+	}
+	return tp.src.open(ctx)
+}
+
+func (tp *timedStreamProvider[T, O]) emit(ctx context.Context) (O, error) {
+	if tp.srcDone {
+		return util.DefaultValue[O](), io.EOF
+	}
+	return tp.emitFunc(ctx)
+}
+
+func (tp *timedStreamProvider[T, O]) close() {
+	if tp.closeFunc != nil {
+		tp.closeFunc()
+	}
+	if tp.abandon {
+		tp.abandonedDone = tp.src.detach()
+		return
+	}
+	tp.src.close()
+}
+
+// closedErr is the error to return once the source channel is closed, see bufferedStreamProvider.emit.
+func (tp *timedStreamProvider[T, O]) closedErr(ctx context.Context) error {
+	tp.srcDone = true
+	if tp.src.eofCtx.Err() != nil {
+		return io.EOF
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	// Should never happen
+	return fmt.Errorf("timed stream channel closed prematurely")
+}
@@ -0,0 +1,131 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestRateLimit(t *testing.T) {
+	clock := NewManualClock(clockTestStart)
+	s := RateLimit(Just(1, 2, 3, 4), 10, 2, WithClockOption(clock))
+
+	var emitted []int
+	done := make(chan error, 1)
+	go func() {
+		done <- s.Consume(context.Background(), func(v int) {
+			emitted = append(emitted, v)
+		})
+	}()
+
+	// The burst is emitted right away, then one element every 100ms
+	clock.BlockUntilWaiters(1)
+	clock.Advance(50 * time.Millisecond)
+	require.Equal(t, 1, clock.Waiters())
+	clock.Advance(50 * time.Millisecond)
+	clock.BlockUntilWaiters(1)
+	clock.Advance(100 * time.Millisecond)
+	require.NoError(t, <-done)
+	require.Equal(t, []int{1, 2, 3, 4}, emitted)
+}
+
+func TestRateLimit_ContextCancellation(t *testing.T) {
+	clock := NewManualClock(clockTestStart)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, err := RateLimit(Just(1, 2, 3), 1, 1, WithClockOption(clock)).Collect(ctx)
+		done <- err
+	}()
+	clock.BlockUntilWaiters(1)
+	cancel()
+	require.ErrorIs(t, <-done, context.Canceled)
+	// The abandoned wait released its timer
+	require.Equal(t, 0, clock.Waiters())
+}
+
+func TestRateLimit_InvalidArguments(t *testing.T) {
+	_, err := RateLimit(Just(1), 0, 1).Collect(context.Background())
+	require.Error(t, err)
+	_, err = RateLimit(Just(1), 1, 0).Collect(context.Background())
+	require.Error(t, err)
+}
+
+func TestThrottle(t *testing.T) {
+	clock := NewManualClock(clockTestStart)
+
+	// Each element arrives after the given delay
+	delays := []time.Duration{0, 300, 300, 300, 300, 1000, 100}
+	src := Map(FromSlice(makeRange(len(delays))), func(i int) int {
+		clock.Advance(delays[i] * time.Millisecond)
+		return i
+	})
+	s := Throttle(src, time.Second, WithClockOption(clock))
+	require.Equal(t, []int{0, 4, 5}, s.MustCollect())
+}
+
+func TestDebounce(t *testing.T) {
+	clock := NewManualClock(clockTestStart)
+	ss, src := newSteppedSource()
+	res := collectAsync(Debounce(src, time.Second, WithClockOption(clock)))
+
+	// 1 is superseded by 2 within the quiet period
+	ss.push(1)
+	clock.BlockUntilWaiters(1)
+	clock.Advance(500 * time.Millisecond)
+	ss.push(2)
+	// The quiet period of 1 has passed, but its timer was replaced by the one of 2
+	clock.Advance(500 * time.Millisecond)
+	clock.BlockUntilWaiters(1)
+	clock.Advance(time.Second)
+
+	// 3 is superseded by 4, and 4 is emitted when the stream ends
+	ss.push(3)
+	clock.BlockUntilWaiters(1)
+	ss.push(4)
+	ss.end()
+
+	require.Equal(t, []int{2, 4}, <-res)
+	// The timers of the superseded and of the last pending elements were all released
+	require.Equal(t, 0, clock.Waiters())
+}
+
+func TestDebounce_SourceError(t *testing.T) {
+	boom := errors.New("boom")
+	_, err := Debounce(errAt(Just(1, 2, 3), 2, boom), time.Second).Collect(context.Background())
+	require.ErrorIs(t, err, boom)
+}
+
+func TestSample(t *testing.T) {
+	clock := NewManualClock(clockTestStart)
+	ss, src := newSteppedSource()
+	res := collectAsync(Sample(src, time.Second, WithClockOption(clock)))
+
+	clock.BlockUntilWaiters(1)
+	ss.push(1)
+	ss.push(2)
+	clock.Advance(time.Second)
+
+	// Nothing arrives during this interval
+	clock.BlockUntilWaiters(1)
+	clock.Advance(time.Second)
+
+	clock.BlockUntilWaiters(1)
+	ss.push(3)
+	clock.Advance(time.Second)
+
+	clock.BlockUntilWaiters(1)
+	ss.push(4)
+	ss.end()
+
+	require.Equal(t, []int{2, 3, 4}, <-res)
+	require.Equal(t, 0, clock.Waiters())
+}
+
+func TestSample_SystemClock(t *testing.T) {
+	// Sampling a fast source ends with its last element
+	res := Sample(FromSlice(makeRange(1000)), time.Hour).MustCollect()
+	require.Equal(t, []int{999}, res)
+}
@@ -0,0 +1,250 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/shpandrak/shpanstream/internal/util"
+	"io"
+	"time"
+)
+
+// RetryPolicy defines when and how a failing stream is re-opened.
+type RetryPolicy struct {
+	// MaxAttempts is the maximal number of consecutive attempts (including the first one) before giving up.
+	// The count is reset once an element is successfully emitted.
+	MaxAttempts int
+
+	// InitialBackoff is the wait before the first retry.
+	InitialBackoff time.Duration
+
+	// BackoffMultiplier multiplies the wait on every consecutive retry, values <= 1 mean constant backoff.
+	BackoffMultiplier float64
+
+	// MaxBackoff caps the wait between retries, zero means no cap.
+	MaxBackoff time.Duration
+
+	// ShouldRetry classifies errors as transient (retryable), nil means retrying all errors.
+	// Context cancellation is never retried.
+	ShouldRetry func(err error) bool
+
+	// OnRetry is an optional observational hook, invoked before waiting for the given retry attempt.
+	OnRetry func(attempt int, err error)
+}
+
+// DefaultRetryPolicy retries up to 3 attempts, with exponential backoff starting at 100ms and capped at 10s.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:       3,
+		InitialBackoff:    100 * time.Millisecond,
+		BackoffMultiplier: 2,
+		MaxBackoff:        10 * time.Second,
+	}
+}
+
+func (rp RetryPolicy) validate() error {
+	if rp.MaxAttempts <= 0 {
+		return fmt.Errorf("retry max attempts must be greater than 0")
+	}
+	if rp.InitialBackoff < 0 || rp.MaxBackoff < 0 {
+		return fmt.Errorf("retry backoff must not be negative")
+	}
+	return nil
+}
+
+func (rp RetryPolicy) backoff(retryNum int) time.Duration {
+	d := float64(rp.InitialBackoff)
+	if rp.BackoffMultiplier > 1 {
+		for i := 1; i < retryNum; i++ {
+			d *= rp.BackoffMultiplier
+			if rp.MaxBackoff > 0 && d >= float64(rp.MaxBackoff) {
+				break
+			}
+		}
+	}
+	if rp.MaxBackoff > 0 && d > float64(rp.MaxBackoff) {
+		return rp.MaxBackoff
+	}
+	return time.Duration(d)
+}
+
+// Retry re-opens the stream when it fails with a transient error (either when opening or emitting),
+// according to the retry policy. Since the stream is re-opened from the beginning, the elements that were
+// already emitted are skipped, so the source is expected to be deterministic (replay the same elements).
+// For sources that can restart from a given position, see RetryWithResume.
+func Retry[T any](s Stream[T], policy RetryPolicy) Stream[T] {
+	return newRetryStream[T, struct{}](
+		func(_ *struct{}) Stream[T] {
+			return s
+		},
+		nil,
+		policy,
+	)
+}
+
+// WithRetry re-opens the stream when it fails with a transient error, see Retry.
+func (s Stream[T]) WithRetry(policy RetryPolicy) Stream[T] {
+	return Retry(s, policy)
+}
+
+// RetryWithResume creates the stream using the streamFactory, and re-creates it when it fails with a transient
+// error according to the retry policy. On every retry, the factory is given the resume token of the last
+// emitted element (nil when no element was emitted yet), allowing the source to resume where it left off
+// rather than replaying from the beginning. e.g. a sql query stream ordered by id, using the last id as the
+// resume token, can be re-created with an "id > lastId" condition.
+func RetryWithResume[T any, K any](
+	streamFactory func(resumeToken *K) Stream[T],
+	resumeTokenFunc func(T) K,
+	policy RetryPolicy,
+) Stream[T] {
+	return newRetryStream(streamFactory, resumeTokenFunc, policy)
+}
+
+func newRetryStream[T any, K any](
+	streamFactory func(resumeToken *K) Stream[T],
+	resumeTokenFunc func(T) K,
+	policy RetryPolicy,
+) Stream[T] {
+	if err := policy.validate(); err != nil {
+		return Error[T](err)
+	}
+	return NewStream[T](&retryStreamProvider[T, K]{
+		streamFactory:   streamFactory,
+		resumeTokenFunc: resumeTokenFunc,
+		policy:          policy,
+	})
+}
+
+type retryStreamProvider[T any, K any] struct {
+	streamFactory func(resumeToken *K) Stream[T]
+	// resumeTokenFunc is nil when the stream is replayed from the beginning, skipping emitted elements
+	resumeTokenFunc func(T) K
+	policy          RetryPolicy
+
+	// Per-consumption state, (re)initialised in Open and torn down in Close.
+	curr       *Stream[T]
+	currCancel context.CancelFunc
+	lastToken  *K
+	emitted    int
+	failures   int
+}
+
+func (rp *retryStreamProvider[T, K]) Open(ctx context.Context) error {
+	rp.lastToken = nil
+	rp.emitted = 0
+	rp.failures = 0
+	return rp.reopen(ctx, nil)
+}
+
+func (rp *retryStreamProvider[T, K]) Emit(ctx context.Context) (T, error) {
+	for {
+		if ctx.Err() != nil {
+			return util.DefaultValue[T](), ctx.Err()
+		}
+		v, err := rp.curr.provider(ctx)
+		if err == nil {
+			rp.failures = 0
+			rp.emitted++
+			if rp.resumeTokenFunc != nil {
+				token := rp.resumeTokenFunc(v)
+				rp.lastToken = &token
+			}
+			return v, nil
+		}
+		if err == io.EOF {
+			return util.DefaultValue[T](), io.EOF
+		}
+		if !rp.shouldRetry(ctx, err) {
+			return util.DefaultValue[T](), err
+		}
+		rp.closeCurr()
+		if err := rp.reopen(ctx, err); err != nil {
+			return util.DefaultValue[T](), err
+		}
+	}
+}
+
+func (rp *retryStreamProvider[T, K]) Close() {
+	rp.closeCurr()
+}
+
+// reopen opens the upstream, retrying open failures. lastErr is the error that caused the reopen (nil for the
+// first open), in which case we back off before trying.
+func (rp *retryStreamProvider[T, K]) reopen(ctx context.Context, lastErr error) error {
+	for {
+		if lastErr != nil {
+			if err := rp.waitBeforeRetry(ctx, lastErr); err != nil {
+				return err
+			}
+		}
+		lastErr = rp.openCurr(ctx)
+		if lastErr == nil {
+			return nil
+		}
+		if !rp.shouldRetry(ctx, lastErr) {
+			return lastErr
+		}
+	}
+}
+
+func (rp *retryStreamProvider[T, K]) openCurr(ctx context.Context) error {
+	s := rp.streamFactory(rp.lastToken)
+	cancel, err := doOpenStream(ctx, s)
+	if err != nil {
+		return err
+	}
+	rp.curr = &s
+	rp.currCancel = cancel
+
+	// When replaying from the beginning, skip what was already emitted
+	if rp.resumeTokenFunc == nil {
+		for i := 0; i < rp.emitted; i++ {
+			if _, err := s.provider(ctx); err != nil {
+				rp.closeCurr()
+				if err == io.EOF {
+					return fmt.Errorf("retried stream ended after %d elements, while %d were already emitted", i, rp.emitted)
+				}
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (rp *retryStreamProvider[T, K]) closeCurr() {
+	if rp.curr != nil {
+		doCloseSubStream(*rp.curr)
+		rp.currCancel()
+		rp.curr = nil
+		rp.currCancel = nil
+	}
+}
+
+func (rp *retryStreamProvider[T, K]) shouldRetry(ctx context.Context, err error) bool {
+	if ctx.Err() != nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if rp.failures+1 >= rp.policy.MaxAttempts {
+		return false
+	}
+	return rp.policy.ShouldRetry == nil || rp.policy.ShouldRetry(err)
+}
+
+func (rp *retryStreamProvider[T, K]) waitBeforeRetry(ctx context.Context, err error) error {
+	rp.failures++
+	if rp.policy.OnRetry != nil {
+		invokeObservationalHook("OnRetry", func() { rp.policy.OnRetry(rp.failures, err) })
+	}
+	d := rp.policy.backoff(rp.failures)
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
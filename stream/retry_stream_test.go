@@ -0,0 +1,180 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"github.com/shpandrak/shpanstream/internal/util"
+	"github.com/stretchr/testify/require"
+	"io"
+	"testing"
+	"time"
+)
+
+var errTransient = errors.New("connection reset")
+
+// flakyStream emits the elements starting at startIdx, failing after failAfter elements on the first
+// failingOpens opens. opens counts the number of times the stream was opened.
+func flakyStream(elements []int, startIdx int, failAfter int, failingOpens int, opens *int) Stream[int] {
+	idx := 0
+	emittedInOpen := 0
+	return NewSimpleStream(
+		func(ctx context.Context) (int, error) {
+			if *opens <= failingOpens && emittedInOpen >= failAfter {
+				return 0, errTransient
+			}
+			if idx >= len(elements) {
+				return 0, io.EOF
+			}
+			v := elements[idx]
+			idx++
+			emittedInOpen++
+			return v, nil
+		},
+		WithOpenFuncOption(func(ctx context.Context) error {
+			*opens++
+			idx = startIdx
+			emittedInOpen = 0
+			return nil
+		}),
+	)
+}
+
+func fastRetryPolicy(maxAttempts int) RetryPolicy {
+	return RetryPolicy{MaxAttempts: maxAttempts, InitialBackoff: time.Millisecond, BackoffMultiplier: 2}
+}
+
+func TestRetry_ReplaysAndSkipsEmitted(t *testing.T) {
+	opens := 0
+	src := flakyStream([]int{1, 2, 3, 4, 5}, 0, 2, 2, &opens)
+	require.Equal(t, []int{1, 2, 3, 4, 5}, Retry(src, fastRetryPolicy(3)).MustCollect())
+	require.Equal(t, 3, opens)
+}
+
+func TestRetry_GivesUpAfterMaxAttempts(t *testing.T) {
+	opens := 0
+	var retries []int
+	policy := fastRetryPolicy(3)
+	policy.OnRetry = func(attempt int, err error) {
+		require.ErrorIs(t, err, errTransient)
+		retries = append(retries, attempt)
+	}
+	src := flakyStream([]int{1, 2, 3}, 0, 0, 100, &opens)
+	_, err := src.WithRetry(policy).Collect(context.Background())
+	require.ErrorIs(t, err, errTransient)
+	require.Equal(t, 3, opens)
+	require.Equal(t, []int{1, 2}, retries)
+}
+
+func TestRetry_AttemptsResetAfterSuccessfulElement(t *testing.T) {
+	// Each open fails after one new element, so every attempt makes progress
+	opens := 0
+	elements := []int{1, 2, 3, 4, 5}
+	idx := 0
+	emittedInOpen := 0
+	progressing := NewSimpleStream(
+		func(ctx context.Context) (int, error) {
+			if idx >= len(elements) {
+				return 0, io.EOF
+			}
+			if emittedInOpen > 0 {
+				return 0, errTransient
+			}
+			emittedInOpen++
+			v := elements[idx]
+			idx++
+			return v, nil
+		},
+		WithOpenFuncOption(func(ctx context.Context) error {
+			opens++
+			emittedInOpen = 0
+			return nil
+		}),
+	)
+	res := RetryWithResume(
+		func(_ *int) Stream[int] {
+			return progressing
+		},
+		util.Identity[int](),
+		fastRetryPolicy(2),
+	).MustCollect()
+	require.Equal(t, elements, res)
+	require.Equal(t, 5, opens)
+}
+
+func TestRetry_NonRetryableError(t *testing.T) {
+	opens := 0
+	policy := fastRetryPolicy(5)
+	policy.ShouldRetry = func(err error) bool {
+		return !errors.Is(err, errTransient)
+	}
+	_, err := Retry(flakyStream([]int{1, 2, 3}, 0, 1, 100, &opens), policy).Collect(context.Background())
+	require.ErrorIs(t, err, errTransient)
+	require.Equal(t, 1, opens)
+}
+
+func TestRetry_OpenFailure(t *testing.T) {
+	attempts := 0
+	src := NewSimpleStream(
+		func(ctx context.Context) (int, error) {
+			return 0, io.EOF
+		},
+		WithOpenFuncOption(func(ctx context.Context) error {
+			attempts++
+			if attempts < 3 {
+				return errTransient
+			}
+			return nil
+		}),
+	)
+	require.Empty(t, Retry(src, fastRetryPolicy(3)).MustCollect())
+	require.Equal(t, 3, attempts)
+}
+
+func TestRetryWithResume(t *testing.T) {
+	elements := []int{10, 20, 30, 40, 50}
+	opens := 0
+	var tokens []*int
+	res := RetryWithResume(
+		func(lastValue *int) Stream[int] {
+			tokens = append(tokens, lastValue)
+			startIdx := 0
+			if lastValue != nil {
+				startIdx = *lastValue / 10
+			}
+			return flakyStream(elements, startIdx, 2, 2, &opens)
+		},
+		util.Identity[int](),
+		fastRetryPolicy(3),
+	).MustCollect()
+	require.Equal(t, elements, res)
+	require.Len(t, tokens, 3)
+	require.Nil(t, tokens[0])
+	require.Equal(t, 20, *tokens[1])
+	require.Equal(t, 40, *tokens[2])
+}
+
+func TestRetry_ContextCancelledDuringBackoff(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	opens := 0
+	policy := RetryPolicy{MaxAttempts: 10, InitialBackoff: time.Hour}
+	_, err := Retry(flakyStream([]int{1}, 0, 0, 100, &opens), policy).Collect(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestRetry_InvalidPolicy(t *testing.T) {
+	_, err := Retry(Just(1), RetryPolicy{}).Collect(context.Background())
+	require.Error(t, err)
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	rp := RetryPolicy{InitialBackoff: time.Second, BackoffMultiplier: 2, MaxBackoff: 5 * time.Second}
+	require.Equal(t, time.Second, rp.backoff(1))
+	require.Equal(t, 2*time.Second, rp.backoff(2))
+	require.Equal(t, 4*time.Second, rp.backoff(3))
+	require.Equal(t, 5*time.Second, rp.backoff(4))
+	require.Equal(t, 5*time.Second, rp.backoff(100))
+
+	constant := RetryPolicy{InitialBackoff: time.Second}
+	require.Equal(t, time.Second, constant.backoff(5))
+}
@@ -0,0 +1,52 @@
+package stream
+
+import (
+	"context"
+	"github.com/shpandrak/shpanstream/internal/util"
+)
+
+// Scan is like Reduce, but emits each intermediate accumulated value rather than only the final one
+// (e.g. running totals). The first element emitted is the accumulation of initialValue with the first source
+// element, so the stream has the same number of elements as the source.
+func Scan[T any, R any](s Stream[T], initialValue R, accumulator func(acc R, v T) R) Stream[R] {
+	return ScanWithErrAndCtx(s, initialValue, func(_ context.Context, acc R, v T) (R, error) {
+		return accumulator(acc, v), nil
+	})
+}
+
+// ScanWithErr is like Scan, where the accumulator may fail the stream by returning an error.
+func ScanWithErr[T any, R any](s Stream[T], initialValue R, accumulator func(acc R, v T) (R, error)) Stream[R] {
+	return ScanWithErrAndCtx(s, initialValue, func(_ context.Context, acc R, v T) (R, error) {
+		return accumulator(acc, v)
+	})
+}
+
+// ScanWithErrAndCtx is like Scan, where the accumulator receives the context and may fail the stream by
+// returning an error.
+func ScanWithErrAndCtx[T any, R any](
+	s Stream[T],
+	initialValue R,
+	accumulator func(ctx context.Context, acc R, v T) (R, error),
+) Stream[R] {
+	var acc R
+	return NewDownStreamSimple(
+		s,
+		func(ctx context.Context, srcProviderFunc ProviderFunc[T]) (R, error) {
+			v, err := srcProviderFunc(ctx)
+			if err != nil {
+				return util.DefaultValue[R](), err
+			}
+			acc, err = accumulator(ctx, acc, v)
+			if err != nil {
+				return util.DefaultValue[R](), err
+			}
+			return acc, nil
+		},
+		func(_ context.Context, _ ProviderFunc[T]) error {
+			// Reset the state to support re-consumption (double collection)
+			acc = initialValue
+			return nil
+		},
+		nil,
+	)
+}
@@ -0,0 +1,59 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestScan(t *testing.T) {
+	s := Scan(Just(1, 2, 3, 4), 0, func(acc, v int) int {
+		return acc + v
+	})
+	require.Equal(t, []int{1, 3, 6, 10}, s.MustCollect())
+	// Re-consuming starts over from the initial value
+	require.Equal(t, []int{1, 3, 6, 10}, s.MustCollect())
+
+	require.Equal(t, []int{3, 3, 5, 5}, Scan(Just(3, 1, 5, 2), 0, func(acc, v int) int {
+		return max(acc, v)
+	}).MustCollect())
+
+	require.Len(t, Scan(Empty[int](), 7, func(acc, v int) int { return acc + v }).MustCollect(), 0)
+}
+
+func TestScan_DifferentAccumulatorType(t *testing.T) {
+	type enriched struct {
+		v     string
+		index int
+	}
+	s := Scan(Just("a", "b", "c"), enriched{index: -1}, func(acc enriched, v string) enriched {
+		return enriched{v: v, index: acc.index + 1}
+	})
+	require.Equal(t, []enriched{{"a", 0}, {"b", 1}, {"c", 2}}, s.MustCollect())
+}
+
+func TestScanWithErr(t *testing.T) {
+	boom := errors.New("boom")
+	var emitted []int
+	err := ScanWithErr(Just(1, 2, 3, 4), 0, func(acc, v int) (int, error) {
+		if v == 3 {
+			return 0, boom
+		}
+		return acc + v, nil
+	}).Consume(context.Background(), func(v int) {
+		emitted = append(emitted, v)
+	})
+	require.ErrorIs(t, err, boom)
+	require.Equal(t, []int{1, 3}, emitted)
+}
+
+func TestScanWithErrAndCtx(t *testing.T) {
+	type ctxKey struct{}
+	ctx := context.WithValue(context.Background(), ctxKey{}, 10)
+	res, err := ScanWithErrAndCtx(Just(1, 2), 0, func(ctx context.Context, acc int, v int) (int, error) {
+		return acc + v*ctx.Value(ctxKey{}).(int), nil
+	}).Collect(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []int{10, 30}, res)
+}
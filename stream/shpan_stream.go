@@ -5,8 +5,6 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"log/slog"
-	"runtime/debug"
 	"slices"
 
 	"github.com/shpandrak/shpanstream"
@@ -92,38 +90,35 @@ func (s Stream[T]) ConsumeWithErr(ctx context.Context, f func(T) error, options
 	}, options...)
 }
 
+// PanicError is the error a panic recovered in the pipeline (e.g. a panicking mapper or consumer function) is
+// returned as. A panic is recovered the same way whether the pipeline is driven by the consuming goroutine or by a
+// background goroutine of a concurrent operator (e.g. WithConcurrentMapOption, WithConcurrentConsumeOption, Buffered
+// or Merge), and a panic recovered in a Lazy reaching the stream (e.g. through FromLazy) is the very same type.
+type PanicError = shpanstream.PanicError
+
 // recoveredPanicToError converts a recovered panic value into the error reported to callers and
-// DoFinally hooks, preserving the error chain when the panic value is an error.
+// DoFinally hooks, preserving the error chain when the panic value is an error. It is called from the
+// deferred recover, so the stack captured is still the one of the panicking goroutine.
 func recoveredPanicToError(rvr any) error {
-	if asErr, ok := rvr.(error); ok {
-		return fmt.Errorf("stream recovered error: %w", asErr)
-	}
-	return fmt.Errorf("stream recovered error value: %v", rvr)
+	return shpanstream.NewPanicError(rvr)
 }
 
-// consumeWithPanicGuard is the consumer-boundary panic guard shared by the sequential and
-// concurrent consume paths: a panic unwinding out of body is logged with its stack and converted
-// into the error the consumer returns. The completed flag (rather than recover() != nil) is what
-// detects unwinding, so a legacy panic(nil) under GODEBUG=panicnil=1 — where recover() returns nil
-// and thereby cancels the panic — surfaces as an error instead of a clean, silently truncated
-// drain. A runtime.Goexit trips the flag too, but the error it fabricates is only assigned to a
-// return value Goexit discards, so it stays harmless.
-func consumeWithPanicGuard(body func() error) (err error) {
-	completed := false
-	defer func() {
-		if completed {
-			return
-		}
-		if rvr := recover(); rvr != nil {
-			slog.Error(fmt.Sprintf("Panic recovered: %v\n%s", rvr, debug.Stack()))
-			err = recoveredPanicToError(rvr)
-			return
-		}
-		err = recoveredPanicToError(nil)
-	}()
-	err = body()
-	completed = true
-	return err
+// consumeWithPanicGuard is the panic guard shared by the sequential and concurrent consume paths,
+// and by the background goroutines of the concurrent operators, see util.RunWithPanicGuard.
+func consumeWithPanicGuard(body func() error) error {
+	return util.RunWithPanicGuard(body)
+}
+
+// callWithPanicGuard calls f under consumeWithPanicGuard, for pulling a provider or calling a mapper on a
+// background goroutine, where an unrecovered panic would crash the process. A panic is returned as the
+// error, so it is passed on like any other error of the pipeline.
+func callWithPanicGuard[V any](f func() (V, error)) (v V, err error) {
+	err = consumeWithPanicGuard(func() error {
+		var fErr error
+		v, fErr = f()
+		return fErr
+	})
+	return v, err
 }
 
 // ConsumeWithErrAndCtx consumes the entire stream and applies the provided function to each element (sometimes named ForEach).
@@ -83,7 +83,10 @@ func (s Stream[T]) doConsumeConcurrently(ctx context.Context, concurrency int, f
 			if workerCtx.Err() != nil {
 				return
 			}
-			v, err := s.provider(workerCtx)
+			// A panic in the pipeline is recovered as the provider error, failing the consumption
+			v, err := callWithPanicGuard(func() (T, error) {
+				return s.provider(workerCtx)
+			})
 			if err != nil {
 				if err == io.EOF {
 					return
@@ -124,7 +127,11 @@ func (s Stream[T]) doConsumeConcurrently(ctx context.Context, concurrency int, f
 						setErr(item.Err)
 						return
 					}
-					if err := f(workerCtx, item.Value); err != nil {
+					// Same as the sequential consume, a panic in the consumer function is returned as its error
+					err := consumeWithPanicGuard(func() error {
+						return f(workerCtx, item.Value)
+					})
+					if err != nil {
 						setErr(err)
 						return
 					}
@@ -3,6 +3,7 @@ package stream
 import (
 	"context"
 	"errors"
+	"github.com/shpandrak/shpanstream/lazy"
 	"github.com/stretchr/testify/require"
 	"testing"
 )
@@ -156,3 +157,87 @@ func (t *testingStreamProvider) Emit(_ context.Context) (int, error) {
 	}
 	return curr, nil
 }
+
+// requirePanicError requires err to be (or wrap) a PanicError recovered from a panic with the value.
+func requirePanicError(t *testing.T, err error, value any) {
+	t.Helper()
+	var panicErr *PanicError
+	require.ErrorAs(t, err, &panicErr)
+	require.Equal(t, value, panicErr.Value)
+	require.NotEmpty(t, panicErr.Stack)
+}
+
+func panickingMapper(i int) int {
+	if i == 3 {
+		panic("mapper boom")
+	}
+	return i
+}
+
+func TestPanicError(t *testing.T) {
+	_, err := Map(Just(1, 2, 3, 4), panickingMapper).Collect(context.Background())
+	requirePanicError(t, err, "mapper boom")
+	require.EqualError(t, err, "shpanstream recovered error value: mapper boom")
+	// The stack is the one of the panicking goroutine
+	require.Contains(t, string(err.(*PanicError).Stack), "panickingMapper")
+
+	boom := errors.New("boom")
+	_, err = Map(Just(1), func(int) int { panic(boom) }).Collect(context.Background())
+	requirePanicError(t, err, boom)
+	require.ErrorIs(t, err, boom)
+	require.EqualError(t, err, "shpanstream recovered error: boom")
+}
+
+func TestPanicError_ConcurrentPaths(t *testing.T) {
+	ctx := context.Background()
+	src := func() Stream[int] {
+		return FromSlice(makeRange(100))
+	}
+	mapped := map[string]Stream[int]{
+		"concurrent map":         Map(src(), panickingMapper, WithConcurrentMapOption(4)),
+		"ordered concurrent map": Map(src(), panickingMapper, WithOrderedConcurrentMapOption(4, 8)),
+		"upstream of concurrent map": Map(Map(src(), panickingMapper), func(i int) int {
+			return i
+		}, WithConcurrentMapOption(4)),
+		"upstream of ordered concurrent map": Map(Map(src(), panickingMapper), func(i int) int {
+			return i
+		}, WithOrderedConcurrentMapOption(4, 8)),
+		"buffered": Buffered(Map(src(), panickingMapper), 4),
+		"merge":    Merge(src(), Map(src(), panickingMapper)),
+		"flat map concurrent": FlatMapConcurrent(src(), func(i int) Stream[int] {
+			return Just(panickingMapper(i))
+		}, 4),
+		"flat map concurrent inner": FlatMapConcurrent(src(), func(i int) Stream[int] {
+			return Map(Just(i), panickingMapper)
+		}, 4),
+	}
+	for name, s := range mapped {
+		t.Run(name, func(t *testing.T) {
+			_, err := s.Collect(ctx)
+			requirePanicError(t, err, "mapper boom")
+		})
+	}
+
+	t.Run("concurrent consume", func(t *testing.T) {
+		err := Map(src(), panickingMapper).Consume(ctx, func(int) {}, WithConcurrentConsumeOption(4))
+		requirePanicError(t, err, "mapper boom")
+	})
+	t.Run("concurrent consumer", func(t *testing.T) {
+		err := src().Consume(ctx, func(i int) { panickingMapper(i) }, WithConcurrentConsumeOption(4))
+		requirePanicError(t, err, "mapper boom")
+	})
+}
+
+func TestPanicError_FromLazy(t *testing.T) {
+	// A panic recovered in a Lazy reaches the stream consumer as the same PanicError
+	memoized := lazy.Memoize(lazy.NewLazy(func(ctx context.Context) (int, error) {
+		panic("mapper boom")
+	}))
+	_, err := FromLazy(memoized).Collect(context.Background())
+	requirePanicError(t, err, "mapper boom")
+
+	// And is recognized as such by the concurrent operators, which stop pulling the panicking source
+	_, err = Map(FromLazy(memoized), func(i int) int { return i }, WithConcurrentMapOption(4)).
+		Collect(context.Background())
+	requirePanicError(t, err, "mapper boom")
+}
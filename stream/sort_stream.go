@@ -0,0 +1,233 @@
+package stream
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/shpandrak/shpanstream"
+	"github.com/shpandrak/shpanstream/internal/util"
+	"io"
+	"os"
+	"slices"
+)
+
+// DefaultSortMaxInMemory is the default number of elements Sort keeps in memory before spilling to disk.
+const DefaultSortMaxInMemory = 100_000
+
+type SortOption func(*sortConfig)
+
+type sortConfig struct {
+	maxInMemory int
+	tempDir     string
+	codec       any
+}
+
+// WithSortMaxInMemoryOption sets the maximal number of elements sorted in memory. When the source stream is
+// larger, sorted runs of this size are spilled to temporary files and merged when the stream is consumed.
+func WithSortMaxInMemoryOption(maxInMemory int) SortOption {
+	return func(cfg *sortConfig) {
+		cfg.maxInMemory = maxInMemory
+	}
+}
+
+// WithSortTempDirOption sets the directory for the spilled sorted runs, default is os.TempDir().
+func WithSortTempDirOption(dir string) SortOption {
+	return func(cfg *sortConfig) {
+		cfg.tempDir = dir
+	}
+}
+
+// WithSortSpillCodecOption sets the codec used for writing and reading the spilled sorted runs,
+// default is GobCodec.
+func WithSortSpillCodecOption[T any](codec Codec[T]) SortOption {
+	return func(cfg *sortConfig) {
+		cfg.codec = codec
+	}
+}
+
+// Sort sorts the stream using the comparator. The sort is stable.
+// Up to WithSortMaxInMemoryOption elements (DefaultSortMaxInMemory by default) are sorted in memory, larger
+// streams are sorted using an external merge sort: sorted runs are spilled to temporary files and k-way merged
+// using MergeSortedStreams. The source stream is fully consumed when the sorted stream is opened, and the
+// temporary files are removed when it is closed.
+func Sort[T any](s Stream[T], comparator shpanstream.Comparator[T], opts ...SortOption) Stream[T] {
+	cfg := sortConfig{
+		maxInMemory: DefaultSortMaxInMemory,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.maxInMemory <= 0 {
+		return Error[T](fmt.Errorf("sort max in memory must be greater than 0"))
+	}
+	codec := GobCodec[T]()
+	if cfg.codec != nil {
+		typedCodec, ok := cfg.codec.(Codec[T])
+		if !ok {
+			return Error[T](fmt.Errorf("sort spill codec %T does not match stream type %T", cfg.codec, util.DefaultValue[T]()))
+		}
+		codec = typedCodec
+	}
+	return NewStream[T](&sortStreamProvider[T]{
+		src:         s,
+		comparator:  comparator,
+		maxInMemory: cfg.maxInMemory,
+		tempDir:     cfg.tempDir,
+		codec:       codec,
+	})
+}
+
+// SortOrdered sorts a stream of ordered elements in ascending order, see Sort.
+func SortOrdered[T cmp.Ordered](s Stream[T], opts ...SortOption) Stream[T] {
+	return Sort(s, shpanstream.ComparatorForOrdered[T](), opts...)
+}
+
+type sortStreamProvider[T any] struct {
+	src         Stream[T]
+	comparator  shpanstream.Comparator[T]
+	maxInMemory int
+	tempDir     string
+	codec       Codec[T]
+
+	// Per-consumption state, (re)initialised in Open and torn down in Close.
+	spillFiles   []string
+	merged       *Stream[T]
+	mergedCancel context.CancelFunc
+	inMemory     []T
+}
+
+func (sp *sortStreamProvider[T]) Open(ctx context.Context) error {
+	var chunk []T
+	err := sp.src.ConsumeWithErr(ctx, func(v T) error {
+		chunk = append(chunk, v)
+		if len(chunk) >= sp.maxInMemory {
+			if err := sp.spill(chunk); err != nil {
+				return err
+			}
+			chunk = chunk[:0]
+		}
+		return nil
+	})
+	if err != nil {
+		sp.removeSpillFiles()
+		return err
+	}
+	slices.SortStableFunc(chunk, sp.comparator)
+
+	if len(sp.spillFiles) == 0 {
+		// Everything fit in memory
+		sp.inMemory = chunk
+		return nil
+	}
+
+	// The runs are merged in source order, and MergeSortedStreams prefers the first stream on ties, keeping
+	// the sort stable
+	runs := make([]Stream[T], 0, len(sp.spillFiles)+1)
+	for _, path := range sp.spillFiles {
+		runs = append(runs, sp.spilledRunStream(path))
+	}
+	if len(chunk) > 0 {
+		runs = append(runs, FromSlice(chunk))
+	}
+	merged := MergeSortedStreams(sp.comparator, runs...)
+	cancel, err := doOpenStream(ctx, merged)
+	if err != nil {
+		sp.removeSpillFiles()
+		return err
+	}
+	sp.merged = &merged
+	sp.mergedCancel = cancel
+	return nil
+}
+
+func (sp *sortStreamProvider[T]) Emit(ctx context.Context) (T, error) {
+	if ctx.Err() != nil {
+		return util.DefaultValue[T](), ctx.Err()
+	}
+	if sp.merged != nil {
+		return sp.merged.provider(ctx)
+	}
+	if len(sp.inMemory) == 0 {
+		return util.DefaultValue[T](), io.EOF
+	}
+	v := sp.inMemory[0]
+	sp.inMemory = sp.inMemory[1:]
+	return v, nil
+}
+
+func (sp *sortStreamProvider[T]) Close() {
+	if sp.merged != nil {
+		doCloseSubStream(*sp.merged)
+		sp.mergedCancel()
+		sp.merged = nil
+		sp.mergedCancel = nil
+	}
+	sp.inMemory = nil
+	sp.removeSpillFiles()
+}
+
+// spill sorts the chunk and writes it to a new temporary file.
+func (sp *sortStreamProvider[T]) spill(chunk []T) (retErr error) {
+	slices.SortStableFunc(chunk, sp.comparator)
+	f, err := os.CreateTemp(sp.tempDir, "shpanstream-sort-*")
+	if err != nil {
+		return fmt.Errorf("failed creating sort spill file: %w", err)
+	}
+	sp.spillFiles = append(sp.spillFiles, f.Name())
+	defer func() {
+		retErr = errors.Join(retErr, f.Close())
+	}()
+
+	w := bufio.NewWriter(f)
+	enc := sp.codec.NewEncoder(w)
+	for _, v := range chunk {
+		if err := enc.Encode(v); err != nil {
+			return fmt.Errorf("failed encoding sort spill file %s: %w", f.Name(), err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed writing sort spill file %s: %w", f.Name(), err)
+	}
+	return nil
+}
+
+func (sp *sortStreamProvider[T]) spilledRunStream(path string) Stream[T] {
+	var f *os.File
+	var dec Decoder[T]
+	return NewSimpleStream(
+		func(ctx context.Context) (T, error) {
+			v, err := dec.Decode()
+			if err != nil {
+				if err == io.EOF {
+					return util.DefaultValue[T](), io.EOF
+				}
+				return util.DefaultValue[T](), fmt.Errorf("failed decoding sort spill file %s: %w", path, err)
+			}
+			return v, nil
+		},
+		WithOpenFuncOption(func(ctx context.Context) error {
+			var err error
+			f, err = os.Open(path)
+			if err != nil {
+				return fmt.Errorf("failed opening sort spill file: %w", err)
+			}
+			dec = sp.codec.NewDecoder(bufio.NewReader(f))
+			return nil
+		}),
+		WithCloseFuncOption(func() {
+			if f != nil {
+				_ = f.Close()
+				f = nil
+			}
+		}),
+	)
+}
+
+func (sp *sortStreamProvider[T]) removeSpillFiles() {
+	for _, path := range sp.spillFiles {
+		_ = os.Remove(path)
+	}
+	sp.spillFiles = nil
+}
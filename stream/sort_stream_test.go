@@ -0,0 +1,123 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"github.com/shpandrak/shpanstream"
+	"github.com/stretchr/testify/require"
+	"math/rand"
+	"os"
+	"slices"
+	"testing"
+)
+
+type sortTestRecord struct {
+	Key   int
+	Order int
+}
+
+func compareSortTestRecords(a, b sortTestRecord) int {
+	return a.Key - b.Key
+}
+
+func requireEmptyDir(t *testing.T, dir string) {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
+
+func TestSort_InMemory(t *testing.T) {
+	dir := t.TempDir()
+	require.Equal(
+		t,
+		[]int{1, 2, 3, 4, 5},
+		SortOrdered(Just(5, 3, 1, 4, 2), WithSortTempDirOption(dir)).MustCollect(),
+	)
+	requireEmptyDir(t, dir)
+}
+
+func TestSort_Spilling(t *testing.T) {
+	dir := t.TempDir()
+	src := make([]int, 1000)
+	for i := range src {
+		src[i] = rand.Intn(100)
+	}
+	expected := slices.Clone(src)
+	slices.Sort(expected)
+
+	sorted := SortOrdered(FromSlice(src), WithSortMaxInMemoryOption(64), WithSortTempDirOption(dir))
+	require.Equal(t, expected, sorted.MustCollect())
+	requireEmptyDir(t, dir)
+
+	// Re-consumption sorts again
+	require.Equal(t, expected, sorted.MustCollect())
+	requireEmptyDir(t, dir)
+}
+
+func TestSort_Stable(t *testing.T) {
+	var src []sortTestRecord
+	for i := 0; i < 100; i++ {
+		src = append(src, sortTestRecord{Key: i % 3, Order: i})
+	}
+
+	for _, maxInMemory := range []int{1, 7, 1000} {
+		sorted := Sort(
+			FromSlice(src),
+			compareSortTestRecords,
+			WithSortMaxInMemoryOption(maxInMemory),
+			WithSortTempDirOption(t.TempDir()),
+		).MustCollect()
+		require.Len(t, sorted, len(src))
+		require.True(t, slices.IsSortedFunc(sorted, func(a, b sortTestRecord) int {
+			if c := compareSortTestRecords(a, b); c != 0 {
+				return c
+			}
+			return a.Order - b.Order
+		}), "sort must be stable for max in memory %d", maxInMemory)
+	}
+}
+
+func TestSort_JsonCodec(t *testing.T) {
+	dir := t.TempDir()
+	sorted := Sort(
+		Just(sortTestRecord{Key: 3}, sortTestRecord{Key: 1}, sortTestRecord{Key: 2}, sortTestRecord{Key: 0}),
+		compareSortTestRecords,
+		WithSortMaxInMemoryOption(2),
+		WithSortTempDirOption(dir),
+		WithSortSpillCodecOption(JsonCodec[sortTestRecord]()),
+	).MustCollect()
+	require.Equal(t, []sortTestRecord{{Key: 0}, {Key: 1}, {Key: 2}, {Key: 3}}, sorted)
+	requireEmptyDir(t, dir)
+}
+
+func TestSort_EarlyTerminationRemovesSpillFiles(t *testing.T) {
+	dir := t.TempDir()
+	first := SortOrdered(Just(9, 8, 7, 6, 5, 4, 3, 2, 1), WithSortMaxInMemoryOption(2), WithSortTempDirOption(dir)).
+		FindFirst().
+		MustGet()
+	require.Equal(t, 1, first)
+	requireEmptyDir(t, dir)
+}
+
+func TestSort_SourceError(t *testing.T) {
+	dir := t.TempDir()
+	expectedErr := errors.New("source failed")
+	src := ConcatStreams(Just(3, 2, 1, 0), Error[int](expectedErr))
+	_, err := SortOrdered(src, WithSortMaxInMemoryOption(2), WithSortTempDirOption(dir)).Collect(context.Background())
+	require.ErrorIs(t, err, expectedErr)
+	requireEmptyDir(t, dir)
+}
+
+func TestSort_Empty(t *testing.T) {
+	require.Empty(t, SortOrdered(Empty[int]()).MustCollect())
+}
+
+func TestSort_InvalidOptions(t *testing.T) {
+	_, err := SortOrdered(Just(1), WithSortMaxInMemoryOption(0)).Collect(context.Background())
+	require.Error(t, err)
+
+	_, err = Sort(Just(1), shpanstream.ComparatorForOrdered[int](), WithSortSpillCodecOption(JsonCodec[string]())).
+		Collect(context.Background())
+	require.Error(t, err)
+}
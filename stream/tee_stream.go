@@ -0,0 +1,306 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/shpandrak/shpanstream/internal/util"
+	"io"
+	"sync"
+)
+
+// DefaultTeeBufferSize is the default number of elements buffered for each Tee branch.
+const DefaultTeeBufferSize = 64
+
+// TeeBackPressure defines what Tee does when a branch lags behind, and its buffer is full.
+type TeeBackPressure int
+
+const (
+	// TeeBackPressureBlock waits for the lagging branch, slowing down the source and all other branches.
+	TeeBackPressureBlock TeeBackPressure = iota
+
+	// TeeBackPressureDrop drops the element for the lagging branch only, the other branches still get it.
+	TeeBackPressureDrop
+
+	// TeeBackPressureError fails the lagging branch with ErrTeeBranchLagging (after it consumed what was
+	// already buffered for it), the other branches keep going.
+	TeeBackPressureError
+)
+
+// ErrTeeBranchLagging is the error a Tee branch fails with when it lags behind, using TeeBackPressureError.
+var ErrTeeBranchLagging = errors.New("tee branch is lagging behind")
+
+type TeeOption func(*teeConfig)
+
+type teeConfig struct {
+	bufferSize   int
+	backPressure TeeBackPressure
+}
+
+// WithTeeBufferSizeOption sets the number of elements buffered for each branch, default is DefaultTeeBufferSize.
+func WithTeeBufferSizeOption(size int) TeeOption {
+	return func(cfg *teeConfig) {
+		cfg.bufferSize = size
+	}
+}
+
+// WithTeeBackPressureOption sets what to do when a branch lags behind, default is TeeBackPressureBlock.
+func WithTeeBackPressureOption(backPressure TeeBackPressure) TeeOption {
+	return func(cfg *teeConfig) {
+		cfg.backPressure = backPressure
+	}
+}
+
+// Tee splits the stream into n branches, each emitting all the elements of the source stream (subject to
+// the back-pressure mode), while the source is consumed only once.
+// The source is opened (on a background goroutine) when the first branch is opened, and closed once all
+// branches were closed. Since branches are fed in lockstep, they are expected to be consumed concurrently
+// (e.g. each on its own goroutine), see Broadcast. Note that in the default TeeBackPressureBlock mode, a
+// branch that is never consumed (nor closed) blocks the others once its buffer is full.
+// Unlike most streams, branches are single-use, and can be consumed only once.
+func Tee[T any](s Stream[T], n int, opts ...TeeOption) []Stream[T] {
+	ts, err := newTeeState(s, n, opts)
+	if err != nil {
+		ret := make([]Stream[T], max(n, 1))
+		for i := range ret {
+			ret[i] = Error[T](err)
+		}
+		return ret
+	}
+	ret := make([]Stream[T], n)
+	for i, b := range ts.branches {
+		ret[i] = NewSimpleStream(b.emit, WithOpenFuncOption(b.open), WithCloseFuncOption(b.close))
+	}
+	return ret
+}
+
+func newTeeState[T any](s Stream[T], n int, opts []TeeOption) (*teeState[T], error) {
+	cfg := teeConfig{
+		bufferSize:   DefaultTeeBufferSize,
+		backPressure: TeeBackPressureBlock,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	var validationErr error
+	switch {
+	case n <= 0:
+		validationErr = fmt.Errorf("tee number of branches must be greater than 0")
+	case cfg.bufferSize <= 0:
+		validationErr = fmt.Errorf("tee buffer size must be greater than 0")
+	case cfg.backPressure < TeeBackPressureBlock || cfg.backPressure > TeeBackPressureError:
+		validationErr = fmt.Errorf("unsupported tee back-pressure mode %d", cfg.backPressure)
+	}
+	if validationErr != nil {
+		return nil, validationErr
+	}
+
+	ts := &teeState[T]{
+		src:          s,
+		backPressure: cfg.backPressure,
+		branches:     make([]*teeBranch[T], n),
+	}
+	for i := range ts.branches {
+		ts.branches[i] = &teeBranch[T]{
+			state:    ts,
+			idx:      i,
+			ch:       make(chan T, cfg.bufferSize),
+			detached: make(chan struct{}),
+		}
+	}
+	return ts, nil
+}
+
+// Broadcast consumes the stream once, feeding all the consumers concurrently, each with its own branch of
+// the stream (see Tee), and waits for all of them to return. When a consumer fails, the context passed to
+// the other consumers is cancelled, and the consumer errors are returned joined. A panicking consumer fails
+// with the recovered panic as its error. A consumer that returns without fully consuming its branch (or
+// without consuming it at all) is detached from the source once it returns, so it does not hold back the
+// others.
+func Broadcast[T any](ctx context.Context, s Stream[T], consumers ...func(ctx context.Context, branch Stream[T]) error) error {
+	if len(consumers) == 0 {
+		return fmt.Errorf("broadcast requires at least one consumer")
+	}
+	ts, err := newTeeState(s, len(consumers), nil)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errs := make([]error, len(consumers))
+	var wg sync.WaitGroup
+	for i, consumer := range consumers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b := ts.branches[i]
+			// The branch is no longer fed once the consumer returned, even if it was never opened
+			defer b.close()
+			// A panicking consumer fails like one returning an error, rather than crashing the process
+			err := consumeWithPanicGuard(func() error {
+				return consumer(ctx, NewSimpleStream(b.emit, WithOpenFuncOption(b.open), WithCloseFuncOption(b.close)))
+			})
+			if err != nil {
+				errs[i] = fmt.Errorf("broadcast consumer %d failed: %w", i, err)
+				cancel()
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// teeState is shared by all the branches of a Tee, and owns the goroutine pumping the source into the
+// branch buffers.
+type teeState[T any] struct {
+	src          Stream[T]
+	backPressure TeeBackPressure
+	branches     []*teeBranch[T]
+
+	mu       sync.Mutex
+	started  bool
+	closed   int
+	cancel   context.CancelFunc
+	pumpDone chan struct{}
+}
+
+type teeBranch[T any] struct {
+	state *teeState[T]
+	idx   int
+	ch    chan T
+	// err is set by the pump before closing ch, nil means the source completed normally
+	err error
+
+	// detached is closed once the branch is closed, so the pump stops feeding it
+	detached chan struct{}
+	opened   bool
+	closed   bool
+}
+
+func (ts *teeState[T]) start(ctx context.Context) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if ts.started {
+		return
+	}
+	ts.started = true
+
+	// The pump outlives the context of the branch that started it, since that branch might be done (and its
+	// context cancelled) long before the others. It is cancelled once all branches are closed.
+	pumpCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	ts.cancel = cancel
+	ts.pumpDone = make(chan struct{})
+	go ts.pump(pumpCtx)
+}
+
+func (ts *teeState[T]) pump(ctx context.Context) {
+	defer close(ts.pumpDone)
+	active := make([]bool, len(ts.branches))
+	for i := range active {
+		active[i] = true
+	}
+	srcErr := ts.src.Consume(ctx, func(v T) {
+		for i, b := range ts.branches {
+			if active[i] {
+				active[i] = ts.feed(ctx, b, v)
+			}
+		}
+	})
+	if srcErr == nil && ctx.Err() != nil {
+		// Cancelled since all branches were closed, no one is listening
+		srcErr = ctx.Err()
+	}
+	for i, b := range ts.branches {
+		if active[i] {
+			b.err = srcErr
+			close(b.ch)
+		}
+	}
+}
+
+// feed sends the element to the branch according to the back-pressure mode, returning false if the branch
+// should no longer be fed.
+func (ts *teeState[T]) feed(ctx context.Context, b *teeBranch[T], v T) bool {
+	switch ts.backPressure {
+	case TeeBackPressureDrop:
+		select {
+		case b.ch <- v:
+		case <-b.detached:
+			return false
+		default:
+		}
+		return true
+	case TeeBackPressureError:
+		select {
+		case b.ch <- v:
+			return true
+		case <-b.detached:
+			return false
+		default:
+			b.err = fmt.Errorf("tee branch %d: %w", b.idx, ErrTeeBranchLagging)
+			close(b.ch)
+			return false
+		}
+	default:
+		select {
+		case b.ch <- v:
+			return true
+		case <-b.detached:
+			return false
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+func (b *teeBranch[T]) open(ctx context.Context) error {
+	b.state.mu.Lock()
+	if b.opened {
+		b.state.mu.Unlock()
+		return fmt.Errorf("tee branch %d can only be consumed once", b.idx)
+	}
+	b.opened = true
+	b.state.mu.Unlock()
+
+	b.state.start(ctx)
+	return nil
+}
+
+func (b *teeBranch[T]) emit(ctx context.Context) (T, error) {
+	select {
+	case <-ctx.Done():
+		return util.DefaultValue[T](), ctx.Err()
+	case v, stillGood := <-b.ch:
+		if !stillGood {
+			// err was set before closing the channel
+			if b.err != nil {
+				return util.DefaultValue[T](), b.err
+			}
+			return util.DefaultValue[T](), io.EOF
+		}
+		return v, nil
+	}
+}
+
+func (b *teeBranch[T]) close() {
+	ts := b.state
+	ts.mu.Lock()
+	if b.closed {
+		ts.mu.Unlock()
+		return
+	}
+	b.closed = true
+	close(b.detached)
+	ts.closed++
+	lastClosed := ts.closed == len(ts.branches) && ts.started
+	ts.mu.Unlock()
+
+	// Once all branches are closed, stop the pump and join it, so the source is fully closed by the time
+	// the last branch reports closed
+	if lastClosed {
+		ts.cancel()
+		<-ts.pumpDone
+	}
+}
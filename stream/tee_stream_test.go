@@ -0,0 +1,258 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// collectConcurrently collects all the streams concurrently, returning the results and errors by index.
+func collectConcurrently[T any](streams []Stream[T]) ([][]T, []error) {
+	results := make([][]T, len(streams))
+	errs := make([]error, len(streams))
+	var wg sync.WaitGroup
+	for i, s := range streams {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = s.Collect(context.Background())
+		}()
+	}
+	wg.Wait()
+	return results, errs
+}
+
+func TestTee(t *testing.T) {
+	var opened, closed atomic.Int32
+	src := FromSlice(makeRange(1000)).WithAdditionalLifecycle(NewLifecycle(
+		func(ctx context.Context) error {
+			opened.Add(1)
+			return nil
+		},
+		func() {
+			closed.Add(1)
+		},
+	))
+
+	results, errs := collectConcurrently(Tee(src, 3, WithTeeBufferSizeOption(4)))
+	for i := range results {
+		require.NoError(t, errs[i])
+		require.Equal(t, makeRange(1000), results[i])
+	}
+	require.EqualValues(t, 1, opened.Load())
+	require.EqualValues(t, 1, closed.Load())
+}
+
+func TestTee_EarlyTerminatedBranch(t *testing.T) {
+	branches := Tee(FromSlice(makeRange(100)), 2, WithTeeBufferSizeOption(1))
+	results, errs := collectConcurrently([]Stream[int]{branches[0].Limit(2), branches[1]})
+	require.NoError(t, errs[0])
+	require.NoError(t, errs[1])
+	require.Equal(t, []int{0, 1}, results[0])
+	require.Equal(t, makeRange(100), results[1])
+}
+
+func TestTee_SourceClosedWhenAllBranchesClosed(t *testing.T) {
+	var closed atomic.Bool
+	pulled := 0
+	infinite := NewSimpleStream(
+		func(ctx context.Context) (int, error) {
+			pulled++
+			return pulled, nil
+		},
+		WithCloseFuncOption(func() {
+			closed.Store(true)
+		}),
+	)
+	branches := Tee(infinite, 2)
+	results, errs := collectConcurrently([]Stream[int]{branches[0].Limit(3), branches[1].Limit(5)})
+	require.NoError(t, errs[0])
+	require.NoError(t, errs[1])
+	require.Equal(t, []int{1, 2, 3}, results[0])
+	require.Equal(t, []int{1, 2, 3, 4, 5}, results[1])
+	require.True(t, closed.Load())
+}
+
+func TestTee_SourceError(t *testing.T) {
+	boom := errors.New("boom")
+	_, errs := collectConcurrently(Tee(errAt(Just(1, 2, 3), 2, boom), 2))
+	require.ErrorIs(t, errs[0], boom)
+	require.ErrorIs(t, errs[1], boom)
+
+	_, errs = collectConcurrently(Tee(Error[int](boom), 2))
+	require.ErrorIs(t, errs[0], boom)
+	require.ErrorIs(t, errs[1], boom)
+}
+
+// lockstepTee tees 1..4 into two branches, where the source only advances once the first branch received the
+// previous element, so the first branch never lags, while the second one is consumed only after.
+func lockstepTee(backPressure TeeBackPressure) (Stream[int], error) {
+	proceed := make(chan struct{})
+	i := 0
+	src := NewSimpleStream(func(ctx context.Context) (int, error) {
+		if i > 0 {
+			<-proceed
+		}
+		if i == 4 {
+			return 0, io.EOF
+		}
+		i++
+		return i, nil
+	})
+	branches := Tee(src, 2, WithTeeBufferSizeOption(1), WithTeeBackPressureOption(backPressure))
+	var first []int
+	err := branches[0].Consume(context.Background(), func(v int) {
+		first = append(first, v)
+		proceed <- struct{}{}
+	})
+	if err != nil {
+		return Stream[int]{}, err
+	}
+	if len(first) != 4 {
+		return Stream[int]{}, fmt.Errorf("unexpected first branch elements: %v", first)
+	}
+	return branches[1], nil
+}
+
+func TestTee_DropBackPressure(t *testing.T) {
+	second, err := lockstepTee(TeeBackPressureDrop)
+	require.NoError(t, err)
+
+	// Only the buffered element is kept for the lagging branch
+	require.Equal(t, []int{1}, second.MustCollect())
+}
+
+func TestTee_ErrorBackPressure(t *testing.T) {
+	second, err := lockstepTee(TeeBackPressureError)
+	require.NoError(t, err)
+
+	var got []int
+	err = second.Consume(context.Background(), func(v int) {
+		got = append(got, v)
+	})
+	require.ErrorIs(t, err, ErrTeeBranchLagging)
+	require.Equal(t, []int{1}, got)
+}
+
+func TestTee_BranchIsSingleUse(t *testing.T) {
+	branches := Tee(Just(1, 2), 1)
+	require.Equal(t, []int{1, 2}, branches[0].MustCollect())
+	_, err := branches[0].Collect(context.Background())
+	require.Error(t, err)
+}
+
+func TestTee_InvalidArguments(t *testing.T) {
+	_, err := Tee(Just(1), 0)[0].Collect(context.Background())
+	require.Error(t, err)
+	_, err = Tee(Just(1), 2, WithTeeBufferSizeOption(0))[1].Collect(context.Background())
+	require.Error(t, err)
+}
+
+func TestBroadcast(t *testing.T) {
+	var collected []int
+	var count int
+	err := Broadcast(
+		context.Background(),
+		FromSlice(makeRange(500)),
+		func(ctx context.Context, branch Stream[int]) error {
+			var err error
+			collected, err = branch.Collect(ctx)
+			return err
+		},
+		func(ctx context.Context, branch Stream[int]) error {
+			var err error
+			count, err = branch.Count(ctx)
+			return err
+		},
+	)
+	require.NoError(t, err)
+	require.Equal(t, makeRange(500), collected)
+	require.Equal(t, 500, count)
+}
+
+func TestBroadcast_ConsumerFailure(t *testing.T) {
+	boom := errors.New("boom")
+	err := Broadcast(
+		context.Background(),
+		FromSlice(makeRange(500)),
+		func(ctx context.Context, branch Stream[int]) error {
+			return branch.ConsumeWithErr(ctx, func(v int) error {
+				if v == 10 {
+					return boom
+				}
+				return nil
+			})
+		},
+		func(ctx context.Context, branch Stream[int]) error {
+			_, err := branch.Count(ctx)
+			return err
+		},
+	)
+	require.ErrorIs(t, err, boom)
+}
+
+func TestBroadcast_Panics(t *testing.T) {
+	countConsumer := func(ctx context.Context, branch Stream[int]) error {
+		_, err := branch.Count(ctx)
+		return err
+	}
+
+	// A panicking consumer
+	err := Broadcast(
+		context.Background(),
+		FromSlice(makeRange(500)),
+		func(ctx context.Context, branch Stream[int]) error {
+			panic("consumer boom")
+		},
+		countConsumer,
+	)
+	requirePanicError(t, err, "consumer boom")
+
+	// A panic in the source, recovered on the pump goroutine, fails all the branches
+	src := Map(FromSlice(makeRange(500)), func(v int) int {
+		if v == 100 {
+			panic("source boom")
+		}
+		return v
+	})
+	err = Broadcast(context.Background(), src, countConsumer, countConsumer)
+	require.ErrorContains(t, err, "broadcast consumer 0 failed")
+	require.ErrorContains(t, err, "broadcast consumer 1 failed")
+	requirePanicError(t, err, "source boom")
+}
+
+func TestBroadcast_ConsumerNotReading(t *testing.T) {
+	tu := newTestStreamUtil(t)
+	src := FromSlice(makeRange(10_000)).WithAdditionalLifecycle(tu.AddLifecycleWatcher("src"))
+	countConsumer := func(ctx context.Context, branch Stream[int]) error {
+		n, err := branch.Limit(1000).Count(ctx)
+		require.Equal(t, 1000, n)
+		return err
+	}
+
+	// A consumer returning without reading its branch does not block the others, and the source is closed
+	err := Broadcast(context.Background(), src, func(ctx context.Context, branch Stream[int]) error {
+		return nil
+	}, countConsumer)
+	require.NoError(t, err)
+	tu.requireAllWatchersVisited()
+
+	// Same for a consumer failing before reading its branch
+	boom := errors.New("boom")
+	tu = newTestStreamUtil(t)
+	src = FromSlice(makeRange(10_000)).WithAdditionalLifecycle(tu.AddLifecycleWatcher("src"))
+	err = Broadcast(context.Background(), src, func(ctx context.Context, branch Stream[int]) error {
+		return boom
+	}, func(ctx context.Context, branch Stream[int]) error {
+		// Not relying on the cancellation by the failing consumer to make progress
+		_, err := branch.Limit(1000).Count(context.WithoutCancel(ctx))
+		return err
+	})
+	require.ErrorIs(t, err, boom)
+	tu.requireAllWatchersVisited()
+}
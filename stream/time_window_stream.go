@@ -0,0 +1,336 @@
+package stream
+
+import (
+	"context"
+	"fmt"
+	"github.com/shpandrak/shpanstream/internal/util"
+	"io"
+	"time"
+)
+
+// TimeWindowReducer reduces a single time window to a value. windowStream yields the window elements
+// (lazily, straight from the source where possible) and windowStart is the start of the window.
+// for tumbling and hopping windows the window ends at windowStart + size (exclusive), for session windows
+// windowStart is the timestamp of the first element in the session.
+type TimeWindowReducer[T any, O any] func(ctx context.Context, windowStart time.Time, windowStream Stream[T]) (O, error)
+
+type TimeWindowOption func(*timeWindowConfig)
+
+type timeWindowConfig struct {
+	hop time.Duration
+}
+
+// WithTimeWindowHopOption sets the interval between window starts, allowing for hopping (sliding) windows.
+// when hop is smaller than the window size windows overlap, and when it is larger, elements falling
+// between windows are dropped.
+func WithTimeWindowHopOption(hop time.Duration) TimeWindowOption {
+	return func(cfg *timeWindowConfig) {
+		cfg.hop = hop
+	}
+}
+
+// TimeWindow groups the elements of a time sorted stream into windows of the given duration, based on the
+// timestamp extracted from each element. By default, windows are tumbling (non overlapping), use
+// WithTimeWindowHopOption for hopping windows.
+// Windows are aligned to the zero time (same as time.Truncate) and only non-empty windows are emitted.
+// An element with a timestamp before the previous one fails the stream, for all window kinds.
+func TimeWindow[T any](
+	s Stream[T],
+	size time.Duration,
+	timestampExtractor func(T) time.Time,
+	opts ...TimeWindowOption,
+) Stream[[]T] {
+	return TimeWindowReduce(s, size, timestampExtractor, collectTimeWindow[T], opts...)
+}
+
+// TimeWindowReduce is like TimeWindow, but instead of collecting each window, it feeds the window
+// elements to the reducer, emitting a single value per window.
+// Tumbling windows are streamed down to the reducer without loading the window into memory (see
+// ClusterSortedStream), hopping windows only keep the elements overlapping with the next window.
+func TimeWindowReduce[T any, O any](
+	s Stream[T],
+	size time.Duration,
+	timestampExtractor func(T) time.Time,
+	reducer TimeWindowReducer[T, O],
+	opts ...TimeWindowOption,
+) Stream[O] {
+	if size <= 0 {
+		return Error[O](fmt.Errorf("time window size must be greater than 0"))
+	}
+	cfg := timeWindowConfig{
+		hop: size, // default: tumbling windows
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.hop <= 0 {
+		return Error[O](fmt.Errorf("time window hop must be greater than 0, or omit the option for tumbling windows"))
+	}
+
+	if cfg.hop == size {
+		return ClusterSortedStream(
+			func(ctx context.Context, windowStart time.Time, windowStream Stream[T], _ *T) (O, error) {
+				return reducer(ctx, windowStart, windowStream)
+			},
+			func(v T) time.Time {
+				return timestampExtractor(v).Truncate(size)
+			},
+			compareTime,
+			requireTimeSorted(s, timestampExtractor),
+		)
+	}
+
+	hp := &hoppingTimeWindowProvider[T, O]{
+		size:               size,
+		hop:                cfg.hop,
+		timestampExtractor: timestampExtractor,
+		reducer:            reducer,
+	}
+	return NewDownStreamSimple(s, hp.emit, hp.open, nil)
+}
+
+// SessionWindow groups the elements of a time sorted stream into sessions. A session ends when the gap
+// between two consecutive elements is greater than the given gap.
+func SessionWindow[T any](s Stream[T], gap time.Duration, timestampExtractor func(T) time.Time) Stream[[]T] {
+	return SessionWindowReduce(s, gap, timestampExtractor, collectTimeWindow[T])
+}
+
+// SessionWindowReduce is like SessionWindow, but instead of collecting each session, it streams the session
+// elements down to the reducer (see ClusterSortedStream), emitting a single value per session.
+func SessionWindowReduce[T any, O any](
+	s Stream[T],
+	gap time.Duration,
+	timestampExtractor func(T) time.Time,
+	reducer TimeWindowReducer[T, O],
+) Stream[O] {
+	if gap <= 0 {
+		return Error[O](fmt.Errorf("session window gap must be greater than 0"))
+	}
+
+	// Tag each element with the start of its session, sessions are then just clusters of the same start
+	var sessionStart, lastTimestamp time.Time
+	started := false
+	tagged := NewDownStreamSimple(
+		s,
+		func(ctx context.Context, srcProviderFunc ProviderFunc[T]) (sessionTaggedElement[T], error) {
+			v, err := srcProviderFunc(ctx)
+			if err != nil {
+				return util.DefaultValue[sessionTaggedElement[T]](), err
+			}
+			ts := timestampExtractor(v)
+			if started && ts.Before(lastTimestamp) {
+				return util.DefaultValue[sessionTaggedElement[T]](), fmt.Errorf("session window stream is not sorted: %v < %v", ts, lastTimestamp)
+			}
+			if !started || ts.Sub(lastTimestamp) > gap {
+				started = true
+				sessionStart = ts
+			}
+			lastTimestamp = ts
+			return sessionTaggedElement[T]{sessionStart: sessionStart, v: v}, nil
+		},
+		func(_ context.Context, _ ProviderFunc[T]) error {
+			// Reset the state to support re-consumption (double collection)
+			started = false
+			return nil
+		},
+		nil,
+	)
+
+	return ClusterSortedStream(
+		func(ctx context.Context, start time.Time, sessionStream Stream[sessionTaggedElement[T]], _ *sessionTaggedElement[T]) (O, error) {
+			return reducer(ctx, start, Map(sessionStream, func(e sessionTaggedElement[T]) T {
+				return e.v
+			}))
+		},
+		func(e sessionTaggedElement[T]) time.Time {
+			return e.sessionStart
+		},
+		compareTime,
+		tagged,
+	)
+}
+
+// requireTimeSorted fails the stream once an element's timestamp is before the previous one, since
+// ClusterSortedStream would otherwise silently split (or merge) windows of an unsorted stream.
+func requireTimeSorted[T any](s Stream[T], timestampExtractor func(T) time.Time) Stream[T] {
+	var lastTimestamp *time.Time
+	return NewDownStreamSimple(
+		s,
+		func(ctx context.Context, srcProviderFunc ProviderFunc[T]) (T, error) {
+			v, err := srcProviderFunc(ctx)
+			if err != nil {
+				return util.DefaultValue[T](), err
+			}
+			ts := timestampExtractor(v)
+			if lastTimestamp != nil && ts.Before(*lastTimestamp) {
+				return util.DefaultValue[T](), fmt.Errorf("time window stream is not sorted: %v < %v", ts, *lastTimestamp)
+			}
+			lastTimestamp = &ts
+			return v, nil
+		},
+		func(_ context.Context, _ ProviderFunc[T]) error {
+			// Reset the state to support re-consumption (double collection)
+			lastTimestamp = nil
+			return nil
+		},
+		nil,
+	)
+}
+
+type sessionTaggedElement[T any] struct {
+	sessionStart time.Time
+	v            T
+}
+
+func collectTimeWindow[T any](ctx context.Context, _ time.Time, windowStream Stream[T]) ([]T, error) {
+	return windowStream.Collect(ctx)
+}
+
+func compareTime(a, b time.Time) int {
+	return a.Compare(b)
+}
+
+// hoppingTimeWindowProvider emits overlapping (or gapped) windows. Since an element can belong to several
+// windows, elements that also belong to the next window are kept in pending, while the rest are streamed
+// straight from the source to the reducer.
+type hoppingTimeWindowProvider[T any, O any] struct {
+	size               time.Duration
+	hop                time.Duration
+	timestampExtractor func(T) time.Time
+	reducer            TimeWindowReducer[T, O]
+
+	// Per-consumption state, reset in open
+	pending       []T
+	srcDone       bool
+	lastTimestamp *time.Time
+	prevStart     *time.Time
+}
+
+func (hp *hoppingTimeWindowProvider[T, O]) open(_ context.Context, _ ProviderFunc[T]) error {
+	hp.pending = nil
+	hp.srcDone = false
+	hp.lastTimestamp = nil
+	hp.prevStart = nil
+	return nil
+}
+
+// pull reads the next element from the source, returning false when the source is done.
+func (hp *hoppingTimeWindowProvider[T, O]) pull(ctx context.Context, srcProviderFunc ProviderFunc[T]) (T, bool, error) {
+	if ctx.Err() != nil {
+		return util.DefaultValue[T](), false, ctx.Err()
+	}
+	v, err := srcProviderFunc(ctx)
+	if err != nil {
+		if err == io.EOF {
+			hp.srcDone = true
+			return util.DefaultValue[T](), false, nil
+		}
+		return util.DefaultValue[T](), false, err
+	}
+	ts := hp.timestampExtractor(v)
+	if hp.lastTimestamp != nil && ts.Before(*hp.lastTimestamp) {
+		return util.DefaultValue[T](), false, fmt.Errorf("time window stream is not sorted: %v < %v", ts, *hp.lastTimestamp)
+	}
+	hp.lastTimestamp = &ts
+	return v, true, nil
+}
+
+func (hp *hoppingTimeWindowProvider[T, O]) emit(ctx context.Context, srcProviderFunc ProviderFunc[T]) (O, error) {
+	var start time.Time
+	for {
+		if len(hp.pending) == 0 {
+			if hp.srcDone {
+				return util.DefaultValue[O](), io.EOF
+			}
+			v, ok, err := hp.pull(ctx, srcProviderFunc)
+			if err != nil {
+				return util.DefaultValue[O](), err
+			}
+			if !ok {
+				return util.DefaultValue[O](), io.EOF
+			}
+			hp.pending = append(hp.pending, v)
+		}
+
+		// The earliest window containing the first pending element, but never a window that was already emitted
+		firstTs := hp.timestampExtractor(hp.pending[0])
+		start = firstTs.Add(-hp.size).Truncate(hp.hop).Add(hp.hop)
+		if hp.prevStart != nil && start.Before(hp.prevStart.Add(hp.hop)) {
+			start = hp.prevStart.Add(hp.hop)
+		}
+
+		// Drop elements that do not belong to any window (only possible when hop is larger than size)
+		hp.dropBefore(start)
+		if len(hp.pending) > 0 {
+			break
+		}
+	}
+
+	end := start.Add(hp.size)
+	nextStart := start.Add(hp.hop)
+	idx := 0
+	windowProviderFunc := func(ctx context.Context) (T, error) {
+		for {
+			if idx < len(hp.pending) {
+				v := hp.pending[idx]
+				if !hp.timestampExtractor(v).Before(end) {
+					return util.DefaultValue[T](), io.EOF
+				}
+				idx++
+				return v, nil
+			}
+			if hp.srcDone {
+				return util.DefaultValue[T](), io.EOF
+			}
+			v, ok, err := hp.pull(ctx, srcProviderFunc)
+			if err != nil {
+				return util.DefaultValue[T](), err
+			}
+			if !ok {
+				return util.DefaultValue[T](), io.EOF
+			}
+			ts := hp.timestampExtractor(v)
+			if ts.Before(end) && ts.Before(nextStart) {
+				// Belongs only to the current window, no need to keep it
+				return v, nil
+			}
+			// Either overlaps with the next window, or is beyond the current one
+			hp.pending = append(hp.pending, v)
+		}
+	}
+
+	result, err := hp.reducer(ctx, start, NewSimpleStream(windowProviderFunc))
+	if err != nil {
+		// Make sure we wrap the error so even if it is io.EOF, it is not mistaken for end of Stream
+		return util.DefaultValue[O](), fmt.Errorf("failed reducing time window: %w", err)
+	}
+
+	// The reducer might have not consumed the entire window (e.g. limit, findFirst), drain the rest
+	for {
+		_, err := windowProviderFunc(ctx)
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return util.DefaultValue[O](), err
+		}
+	}
+
+	hp.dropBefore(nextStart)
+	hp.prevStart = &start
+	return result, nil
+}
+
+// dropBefore removes pending elements with a timestamp before t (pending is sorted).
+func (hp *hoppingTimeWindowProvider[T, O]) dropBefore(t time.Time) {
+	k := 0
+	for k < len(hp.pending) && hp.timestampExtractor(hp.pending[k]).Before(t) {
+		k++
+	}
+	if k > 0 {
+		n := copy(hp.pending, hp.pending[k:])
+		// Clear the tail so dropped elements can be garbage collected
+		clear(hp.pending[n:])
+		hp.pending = hp.pending[:n]
+	}
+}
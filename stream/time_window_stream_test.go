@@ -0,0 +1,223 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+type timedEvent struct {
+	ts time.Time
+	v  int
+}
+
+var timeWindowBase = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func eventAt(minutes int, v int) timedEvent {
+	return timedEvent{ts: timeWindowBase.Add(time.Duration(minutes) * time.Minute), v: v}
+}
+
+func eventTimestamp(e timedEvent) time.Time {
+	return e.ts
+}
+
+func eventValues(windows [][]timedEvent) [][]int {
+	var ret [][]int
+	for _, w := range windows {
+		var values []int
+		for _, e := range w {
+			values = append(values, e.v)
+		}
+		ret = append(ret, values)
+	}
+	return ret
+}
+
+func sumEvents(ctx context.Context, _ time.Time, windowStream Stream[timedEvent]) (int, error) {
+	return Reduce(ctx, windowStream, 0, func(acc int, e timedEvent) int {
+		return acc + e.v
+	})
+}
+
+func TestTimeWindow_Tumbling(t *testing.T) {
+	src := Just(eventAt(0, 1), eventAt(3, 2), eventAt(10, 3), eventAt(14, 4), eventAt(31, 5))
+	require.Equal(
+		t,
+		[][]int{{1, 2}, {3, 4}, {5}},
+		eventValues(TimeWindow(src, 10*time.Minute, eventTimestamp).MustCollect()),
+	)
+}
+
+func TestTimeWindowReduce_Tumbling(t *testing.T) {
+	src := Just(eventAt(0, 1), eventAt(3, 2), eventAt(10, 3), eventAt(14, 4), eventAt(31, 5))
+
+	var starts []time.Time
+	res := TimeWindowReduce(
+		src,
+		10*time.Minute,
+		eventTimestamp,
+		func(ctx context.Context, windowStart time.Time, windowStream Stream[timedEvent]) (int, error) {
+			starts = append(starts, windowStart)
+			return sumEvents(ctx, windowStart, windowStream)
+		},
+	).MustCollect()
+	require.Equal(t, []int{3, 7, 5}, res)
+	require.Equal(t, []time.Time{
+		timeWindowBase,
+		timeWindowBase.Add(10 * time.Minute),
+		timeWindowBase.Add(30 * time.Minute),
+	}, starts)
+}
+
+func TestTimeWindow_Hopping(t *testing.T) {
+	// Element at minute 0 belongs to both [-5,5) and [0,10)
+	src := Just(eventAt(0, 1), eventAt(5, 2), eventAt(10, 3), eventAt(15, 4))
+	require.Equal(
+		t,
+		[][]int{{1}, {1, 2}, {2, 3}, {3, 4}, {4}},
+		eventValues(TimeWindow(src, 10*time.Minute, eventTimestamp, WithTimeWindowHopOption(5*time.Minute)).MustCollect()),
+	)
+}
+
+func TestTimeWindow_HoppingFirstWindowStartsBeforeFirstElement(t *testing.T) {
+	// First element at minute 7, windows of 10 minutes every 5 minutes: [0,10) is the first window containing it
+	src := Just(eventAt(7, 1), eventAt(12, 2))
+
+	var starts []time.Time
+	res := TimeWindowReduce(
+		src,
+		10*time.Minute,
+		eventTimestamp,
+		func(ctx context.Context, windowStart time.Time, windowStream Stream[timedEvent]) (int, error) {
+			starts = append(starts, windowStart)
+			return sumEvents(ctx, windowStart, windowStream)
+		},
+		WithTimeWindowHopOption(5*time.Minute),
+	).MustCollect()
+	require.Equal(t, []int{1, 3, 2}, res)
+	require.Equal(t, []time.Time{
+		timeWindowBase,
+		timeWindowBase.Add(5 * time.Minute),
+		timeWindowBase.Add(10 * time.Minute),
+	}, starts)
+}
+
+func TestTimeWindow_HoppingSkipsEmptyWindows(t *testing.T) {
+	src := Just(eventAt(0, 1), eventAt(60, 2))
+	require.Equal(
+		t,
+		[][]int{{1}, {1}, {2}, {2}},
+		eventValues(TimeWindow(src, 10*time.Minute, eventTimestamp, WithTimeWindowHopOption(5*time.Minute)).MustCollect()),
+	)
+}
+
+func TestTimeWindow_HopLargerThanSize(t *testing.T) {
+	// 5 minutes windows every 10 minutes, elements between windows are dropped
+	src := Just(eventAt(1, 1), eventAt(6, 2), eventAt(11, 3), eventAt(17, 4), eventAt(21, 5))
+	require.Equal(
+		t,
+		[][]int{{1}, {3}, {5}},
+		eventValues(TimeWindow(src, 5*time.Minute, eventTimestamp, WithTimeWindowHopOption(10*time.Minute)).MustCollect()),
+	)
+}
+
+func TestTimeWindow_HoppingPartialReducer(t *testing.T) {
+	src := Just(eventAt(0, 1), eventAt(1, 2), eventAt(5, 3), eventAt(6, 4), eventAt(12, 5))
+
+	// Reducer only reads the first element of each window, the rest must still be accounted for
+	res := TimeWindowReduce(
+		src,
+		10*time.Minute,
+		eventTimestamp,
+		func(ctx context.Context, _ time.Time, windowStream Stream[timedEvent]) (int, error) {
+			e, err := windowStream.FindFirst().Get(ctx)
+			return e.v, err
+		},
+		WithTimeWindowHopOption(5*time.Minute),
+	).MustCollect()
+	require.Equal(t, []int{1, 1, 3, 5}, res)
+}
+
+func TestTimeWindow_Empty(t *testing.T) {
+	require.Empty(t, TimeWindow(Empty[timedEvent](), time.Minute, eventTimestamp).MustCollect())
+	require.Empty(t, TimeWindow(Empty[timedEvent](), time.Minute, eventTimestamp, WithTimeWindowHopOption(time.Second)).MustCollect())
+	require.Empty(t, SessionWindow(Empty[timedEvent](), time.Minute, eventTimestamp).MustCollect())
+}
+
+func TestTimeWindow_InvalidArgs(t *testing.T) {
+	_, err := TimeWindow(Just(eventAt(0, 1)), 0, eventTimestamp).Collect(context.Background())
+	require.Error(t, err)
+
+	_, err = TimeWindow(Just(eventAt(0, 1)), time.Minute, eventTimestamp, WithTimeWindowHopOption(0)).Collect(context.Background())
+	require.Error(t, err)
+
+	_, err = SessionWindow(Just(eventAt(0, 1)), 0, eventTimestamp).Collect(context.Background())
+	require.Error(t, err)
+}
+
+func TestTimeWindow_HoppingNotSorted(t *testing.T) {
+	src := Just(eventAt(10, 1), eventAt(3, 2))
+	_, err := TimeWindow(src, 10*time.Minute, eventTimestamp, WithTimeWindowHopOption(5*time.Minute)).Collect(context.Background())
+	require.Error(t, err)
+}
+
+func TestTimeWindow_TumblingNotSorted(t *testing.T) {
+	// Across windows
+	_, err := TimeWindow(Just(eventAt(10, 1), eventAt(3, 2)), 10*time.Minute, eventTimestamp).Collect(context.Background())
+	require.ErrorContains(t, err, "not sorted")
+
+	// Within a window
+	_, err = TimeWindow(Just(eventAt(3, 1), eventAt(1, 2)), 10*time.Minute, eventTimestamp).Collect(context.Background())
+	require.ErrorContains(t, err, "not sorted")
+
+	// Equal timestamps are sorted
+	src := Just(eventAt(1, 1), eventAt(1, 2), eventAt(11, 3))
+	require.Equal(t, [][]int{{1, 2}, {3}}, eventValues(TimeWindow(src, 10*time.Minute, eventTimestamp).MustCollect()))
+}
+
+func TestTimeWindow_ReducerError(t *testing.T) {
+	expectedErr := errors.New("reducer failed")
+	_, err := TimeWindowReduce(
+		Just(eventAt(0, 1)),
+		time.Minute,
+		eventTimestamp,
+		func(_ context.Context, _ time.Time, _ Stream[timedEvent]) (int, error) {
+			return 0, expectedErr
+		},
+		WithTimeWindowHopOption(30*time.Second),
+	).Collect(context.Background())
+	require.ErrorIs(t, err, expectedErr)
+}
+
+func TestSessionWindow(t *testing.T) {
+	src := Just(eventAt(0, 1), eventAt(4, 2), eventAt(9, 3), eventAt(30, 4), eventAt(33, 5), eventAt(60, 6))
+	sessions := SessionWindow(src, 5*time.Minute, eventTimestamp)
+	require.Equal(t, [][]int{{1, 2, 3}, {4, 5}, {6}}, eventValues(sessions.MustCollect()))
+
+	// Re-consumption restarts the sessions
+	require.Equal(t, [][]int{{1, 2, 3}, {4, 5}, {6}}, eventValues(sessions.MustCollect()))
+}
+
+func TestSessionWindowReduce(t *testing.T) {
+	src := Just(eventAt(0, 1), eventAt(4, 2), eventAt(30, 4), eventAt(33, 5))
+
+	var starts []time.Time
+	res := SessionWindowReduce(
+		src,
+		5*time.Minute,
+		eventTimestamp,
+		func(ctx context.Context, windowStart time.Time, windowStream Stream[timedEvent]) (int, error) {
+			starts = append(starts, windowStart)
+			return sumEvents(ctx, windowStart, windowStream)
+		},
+	).MustCollect()
+	require.Equal(t, []int{3, 9}, res)
+	require.Equal(t, []time.Time{timeWindowBase, timeWindowBase.Add(30 * time.Minute)}, starts)
+}
+
+func TestSessionWindow_NotSorted(t *testing.T) {
+	_, err := SessionWindow(Just(eventAt(10, 1), eventAt(3, 2)), time.Minute, eventTimestamp).Collect(context.Background())
+	require.Error(t, err)
+}
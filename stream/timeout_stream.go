@@ -0,0 +1,95 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/shpandrak/shpanstream/internal/util"
+	"time"
+)
+
+// ErrElementTimeout is the error (wrapped) a Timeout stream fails with, when the next element did not arrive in time.
+var ErrElementTimeout = errors.New("stream element timeout")
+
+// ErrStreamDeadlineExceeded is the error (wrapped) a WithDeadline stream fails with, when the consumption did not
+// complete in time.
+var ErrStreamDeadlineExceeded = errors.New("stream deadline exceeded")
+
+// Timeout fails the stream with ErrElementTimeout when the next element does not arrive within perElement
+// since it was requested, e.g. a stalled websocket or database cursor.
+// The source is read on a background goroutine, so a provider that is blocked (even one ignoring the context)
+// cannot hang the consumer: once timed out, the stream is closed without waiting for the source, which is closed
+// in the background once its provider returns (and re-consuming the stream waits for that).
+// The error is distinguishable from upstream errors (and from context errors) using errors.Is, including in
+// DoFinally hooks placed downstream.
+func Timeout[T any](s Stream[T], perElement time.Duration, opts ...TimeOption) Stream[T] {
+	if perElement <= 0 {
+		return Error[T](fmt.Errorf("timeout must be greater than 0"))
+	}
+	tp := &timedStreamProvider[T, T]{
+		src:   &bufferedStreamProvider[T]{src: s, size: 1},
+		clock: newTimeConfig(opts).clock,
+	}
+	tp.emitFunc = func(ctx context.Context) (T, error) {
+		timer := tp.clock.NewTimer(perElement)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return util.DefaultValue[T](), ctx.Err()
+		case r, stillGood := <-tp.src.bufferChan:
+			if !stillGood {
+				return util.DefaultValue[T](), tp.closedErr(ctx)
+			}
+			return r.Unpack()
+		case <-timer.C():
+			tp.abandon = true
+			return util.DefaultValue[T](), fmt.Errorf("%w: no element within %s", ErrElementTimeout, perElement)
+		}
+	}
+	return NewSimpleStream(tp.emit, WithOpenFuncOption(tp.open), WithCloseFuncOption(tp.close))
+}
+
+// WithDeadline fails the stream with ErrStreamDeadlineExceeded when it was not fully consumed within total since
+// it was opened. Like Timeout, the source is read on a background goroutine (and is not waited for once the
+// deadline expired), and the error is distinguishable from upstream errors using errors.Is.
+func WithDeadline[T any](s Stream[T], total time.Duration, opts ...TimeOption) Stream[T] {
+	if total <= 0 {
+		return Error[T](fmt.Errorf("deadline must be greater than 0"))
+	}
+	dp := &timedStreamProvider[T, T]{
+		src:   &bufferedStreamProvider[T]{src: s, size: 1},
+		clock: newTimeConfig(opts).clock,
+	}
+	// The deadline is set once per consumption
+	var deadline Timer
+	var expired bool
+	dp.openFunc = func() {
+		deadline = dp.clock.NewTimer(total)
+		expired = false
+	}
+	dp.closeFunc = func() {
+		deadline.Stop()
+	}
+	deadlineErr := func() error {
+		return fmt.Errorf("%w: not completed within %s", ErrStreamDeadlineExceeded, total)
+	}
+	dp.emitFunc = func(ctx context.Context) (T, error) {
+		if expired {
+			return util.DefaultValue[T](), deadlineErr()
+		}
+		select {
+		case <-ctx.Done():
+			return util.DefaultValue[T](), ctx.Err()
+		case r, stillGood := <-dp.src.bufferChan:
+			if !stillGood {
+				return util.DefaultValue[T](), dp.closedErr(ctx)
+			}
+			return r.Unpack()
+		case <-deadline.C():
+			expired = true
+			dp.abandon = true
+			return util.DefaultValue[T](), deadlineErr()
+		}
+	}
+	return NewSimpleStream(dp.emit, WithOpenFuncOption(dp.open), WithCloseFuncOption(dp.close))
+}
@@ -0,0 +1,168 @@
+package stream
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+// stalledStream emits the given elements, and then blocks until the context is done.
+func stalledStream(elements ...int) Stream[int] {
+	return ConcatStreams(Just(elements...), NewSimpleStream(func(ctx context.Context) (int, error) {
+		<-ctx.Done()
+		return 0, ctx.Err()
+	}))
+}
+
+func TestTimeout(t *testing.T) {
+	clock := NewManualClock(clockTestStart)
+	var finallyErr error
+	s := Timeout(stalledStream(1, 2), time.Second, WithClockOption(clock)).
+		DoFinally(func(err error) {
+			finallyErr = err
+		})
+
+	emitted := make(chan int)
+	done := make(chan error, 1)
+	go func() {
+		done <- s.Consume(context.Background(), func(v int) {
+			emitted <- v
+		})
+	}()
+
+	// Each requested element starts a timer (stopped once the element arrived), the third one is never going
+	// to arrive
+	require.Equal(t, 1, <-emitted)
+	require.Equal(t, 2, <-emitted)
+	clock.BlockUntilWaiters(1)
+	clock.Advance(time.Second)
+
+	err := <-done
+	require.ErrorIs(t, err, ErrElementTimeout)
+	require.NotErrorIs(t, err, context.DeadlineExceeded)
+	require.ErrorIs(t, finallyErr, ErrElementTimeout)
+}
+
+// stuckStream emits the given elements, and then blocks (ignoring the context) until released, returning a func
+// that releases it and waits for the stream to be closed.
+func stuckStream(elements ...int) (Stream[int], func()) {
+	release := make(chan struct{})
+	closed := make(chan struct{})
+	var closeOnce sync.Once
+	stuck := NewSimpleStream(
+		func(ctx context.Context) (int, error) {
+			<-release
+			return 0, io.EOF
+		},
+		WithCloseFuncOption(func() {
+			closeOnce.Do(func() { close(closed) })
+		}),
+	)
+	return ConcatStreams(Just(elements...), stuck), func() {
+		close(release)
+		<-closed
+	}
+}
+
+func TestTimeout_StuckSourceDoesNotHangTheConsumer(t *testing.T) {
+	for name, timed := range map[string]func(s Stream[int], clock Clock) Stream[int]{
+		"timeout": func(s Stream[int], clock Clock) Stream[int] {
+			return Timeout(s, time.Second, WithClockOption(clock))
+		},
+		"deadline": func(s Stream[int], clock Clock) Stream[int] {
+			return WithDeadline(s, time.Second, WithClockOption(clock))
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			clock := NewManualClock(clockTestStart)
+			src, release := stuckStream(1)
+			s := timed(src, clock)
+
+			emitted := make(chan int)
+			done := make(chan error, 1)
+			go func() {
+				done <- s.Consume(context.Background(), func(v int) {
+					emitted <- v
+				})
+			}()
+			require.Equal(t, 1, <-emitted)
+			clock.BlockUntilWaiters(1)
+			clock.Advance(time.Second)
+
+			select {
+			case err := <-done:
+				require.Error(t, err)
+			case <-time.After(5 * time.Second):
+				require.Fail(t, "the consumer hangs on the stuck source")
+			}
+
+			// Re-consuming waits for the stuck source to be released (and closed)
+			go release()
+			require.Equal(t, []int{1}, s.MustCollect())
+		})
+	}
+}
+
+func TestTimeout_TimersAreStopped(t *testing.T) {
+	clock := NewManualClock(clockTestStart)
+	require.Equal(t, []int{1, 2, 3}, Timeout(Just(1, 2, 3), time.Second, WithClockOption(clock)).MustCollect())
+	require.Equal(t, []int{1, 2, 3}, WithDeadline(Just(1, 2, 3), time.Second, WithClockOption(clock)).MustCollect())
+	require.Zero(t, clock.Waiters())
+}
+
+func TestTimeout_NoTimeout(t *testing.T) {
+	clock := NewManualClock(clockTestStart)
+	s := Timeout(Just(1, 2, 3), time.Second, WithClockOption(clock))
+	require.Equal(t, []int{1, 2, 3}, s.MustCollect())
+	require.Equal(t, []int{1, 2, 3}, s.MustCollect())
+}
+
+func TestTimeout_UpstreamDeadlineIsNotATimeout(t *testing.T) {
+	_, err := Timeout(Error[int](context.DeadlineExceeded), time.Hour).Collect(context.Background())
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.NotErrorIs(t, err, ErrElementTimeout)
+}
+
+func TestWithDeadline(t *testing.T) {
+	clock := NewManualClock(clockTestStart)
+	var finallyErr error
+	s := WithDeadline(stalledStream(1, 2), time.Minute, WithClockOption(clock)).
+		DoFinally(func(err error) {
+			finallyErr = err
+		})
+
+	emitted := make(chan int)
+	done := make(chan error, 1)
+	go func() {
+		done <- s.Consume(context.Background(), func(v int) {
+			emitted <- v
+		})
+	}()
+
+	// The elements that arrived before the deadline are emitted
+	require.Equal(t, 1, <-emitted)
+	require.Equal(t, 2, <-emitted)
+	clock.Advance(time.Minute)
+
+	err := <-done
+	require.ErrorIs(t, err, ErrStreamDeadlineExceeded)
+	require.NotErrorIs(t, err, ErrElementTimeout)
+	require.ErrorIs(t, finallyErr, ErrStreamDeadlineExceeded)
+}
+
+func TestWithDeadline_CompletedInTime(t *testing.T) {
+	clock := NewManualClock(clockTestStart)
+	s := WithDeadline(Just(1, 2, 3), time.Second, WithClockOption(clock))
+	require.Equal(t, []int{1, 2, 3}, s.MustCollect())
+	require.Equal(t, []int{1, 2, 3}, s.MustCollect())
+}
+
+func TestTimeout_InvalidArguments(t *testing.T) {
+	_, err := Timeout(Just(1), 0).Collect(context.Background())
+	require.Error(t, err)
+	_, err = WithDeadline(Just(1), 0).Collect(context.Background())
+	require.Error(t, err)
+}
@@ -1,5 +1,15 @@
 package stream
 
+import (
+	"context"
+	"fmt"
+	"github.com/shpandrak/shpanstream/internal/util"
+	"io"
+	"iter"
+)
+
+// Iterator allows ranging over the stream elements. Since it has no context and no way to report errors, an
+// error panics inside the loop, prefer All for streams that may fail (e.g. sql, file or websocket streams).
 func (s Stream[T]) Iterator(yield func(T) bool) {
 	s.Filter(func(v T) bool {
 		// Yield return false if we need to stop (e.g. break within the loop)
@@ -8,6 +18,7 @@ func (s Stream[T]) Iterator(yield func(T) bool) {
 		MustGetOptional()
 }
 
+// IndexedIterator is like Iterator, ranging over the stream elements along with their index.
 func (s Stream[T]) IndexedIterator(yield func(int, T) bool) {
 	// Use a counter to keep track of the index
 	index := -1
@@ -18,3 +29,70 @@ func (s Stream[T]) IndexedIterator(yield func(int, T) bool) {
 	}).FindFirst().
 		MustGetOptional()
 }
+
+// All returns an iterator ranging over the stream elements, for consuming the stream with a for range loop.
+// When the stream fails (or the context is done), the error is yielded as the last iteration, along with the
+// zero value of T. Breaking out of the loop stops the stream, closing it (and its providers) right away.
+// A panic raised by the stream is yielded as an error (like Consume does), while a panic raised by the loop
+// body propagates as is.
+func (s Stream[T]) All(ctx context.Context) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		cancelFunc, err := doOpenStream[T](ctx, s)
+		if err != nil {
+			yield(util.DefaultValue[T](), fmt.Errorf("failed to open stream: %w", err))
+			return
+		}
+		defer func() {
+			doCloseSubStream(s)
+			cancelFunc()
+		}()
+
+		for {
+			// Make sure to check if the context is done before trying to get the next item
+			if ctx.Err() != nil {
+				yield(util.DefaultValue[T](), ctx.Err())
+				return
+			}
+			// Guarding only the provider, so the loop body panics are not turned into errors
+			var v T
+			err := consumeWithPanicGuard(func() error {
+				var err error
+				v, err = s.provider(ctx)
+				return err
+			})
+			if err != nil {
+				if err != io.EOF {
+					yield(util.DefaultValue[T](), err)
+				}
+				return
+			}
+			if !yield(v, nil) {
+				return
+			}
+		}
+	}
+}
+
+// Pull converts the stream to a pull style iterator (see iter.Pull). Each call to next returns the next element
+// with ok set to true, or ok set to false once the stream terminated, along with the error it failed with (nil
+// when it completed). stop must be called once done with the iterator (it is safe to call after the stream
+// terminated, and more than once), closing the stream if it was not fully consumed.
+// Once the stream failed, any later call to next keeps returning the error it failed with.
+func (s Stream[T]) Pull(ctx context.Context) (next func() (T, bool, error), stop func()) {
+	pullNext, stop := iter.Pull2(s.All(ctx))
+	var terminalErr error
+	return func() (T, bool, error) {
+		if terminalErr != nil {
+			return util.DefaultValue[T](), false, terminalErr
+		}
+		v, err, ok := pullNext()
+		if !ok {
+			return util.DefaultValue[T](), false, nil
+		}
+		if err != nil {
+			terminalErr = err
+			return util.DefaultValue[T](), false, err
+		}
+		return v, true, nil
+	}, stop
+}
@@ -1,7 +1,11 @@
 package stream
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"github.com/stretchr/testify/require"
+	"testing"
 )
 
 func ExampleStream_Iterator() {
@@ -17,3 +21,149 @@ func ExampleStream_Iterator() {
 	fmt.Println(msg)
 
 }
+
+func ExampleStream_All() {
+	msg := "using All:"
+	for curr, err := range Just(1, 1, 2, 3, 5, 8, 13, 21, 34, 55).All(context.Background()) {
+		if err != nil {
+			msg += fmt.Sprintf(" error: %v", err)
+			break
+		}
+		if curr == 21 {
+			break
+		}
+		msg += fmt.Sprintf(" %d", curr)
+	}
+
+	// Output: using All: 1 1 2 3 5 8 13
+	fmt.Println(msg)
+}
+
+func TestStream_All_Error(t *testing.T) {
+	boom := errors.New("boom")
+	var values []int
+	var errs []error
+	for v, err := range errAt(FromSlice(makeRange(10)), 3, boom).All(context.Background()) {
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		values = append(values, v)
+	}
+	require.Equal(t, []int{0, 1, 2}, values)
+	require.Len(t, errs, 1)
+	require.ErrorIs(t, errs[0], boom)
+
+	// Open failure
+	for _, err := range Error[int](boom).All(context.Background()) {
+		require.ErrorIs(t, err, boom)
+	}
+}
+
+func TestStream_All_BreakClosesStream(t *testing.T) {
+	tu := newTestStreamUtil(t)
+	for v, err := range FromSlice(makeRange(10)).WithAdditionalLifecycle(tu.AddLifecycleWatcher("1")).All(context.Background()) {
+		require.NoError(t, err)
+		if v == 3 {
+			break
+		}
+	}
+	tu.requireAllWatchersVisited()
+}
+
+func TestStream_All_ContextCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var lastErr error
+	count := 0
+	for _, err := range FromSlice(makeRange(10)).All(ctx) {
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		count++
+		if count == 2 {
+			cancel()
+		}
+	}
+	require.Equal(t, 2, count)
+	require.ErrorIs(t, lastErr, context.Canceled)
+}
+
+func TestStream_All_LoopBodyPanicPropagates(t *testing.T) {
+	tu := newTestStreamUtil(t)
+	require.PanicsWithValue(t, "body", func() {
+		for range Just(1, 2).WithAdditionalLifecycle(tu.AddLifecycleWatcher("1")).All(context.Background()) {
+			panic("body")
+		}
+	})
+	tu.requireAllWatchersVisited()
+}
+
+func TestStream_Pull(t *testing.T) {
+	next, stop := Just(1, 2).Pull(context.Background())
+	defer stop()
+	v, ok, err := next()
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, 1, v)
+	v, ok, err = next()
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, 2, v)
+	_, ok, err = next()
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	// The error is returned once the stream terminated
+	boom := errors.New("boom")
+	next, stop = errAt(Just(1, 2), 2, boom).Pull(context.Background())
+	defer stop()
+	v, ok, err = next()
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, 1, v)
+	_, ok, err = next()
+	require.ErrorIs(t, err, boom)
+	require.False(t, ok)
+}
+
+func TestStream_Pull_ErrorIsTerminal(t *testing.T) {
+	boom := errors.New("boom")
+	next, stop := errAt(Just(1, 2), 1, boom).Pull(context.Background())
+	defer stop()
+
+	// Once failed, the stream keeps reporting the same error, also after stop
+	for range 3 {
+		v, ok, err := next()
+		require.ErrorIs(t, err, boom)
+		require.False(t, ok)
+		require.Zero(t, v)
+	}
+	stop()
+	_, ok, err := next()
+	require.ErrorIs(t, err, boom)
+	require.False(t, ok)
+
+	// A completed stream keeps reporting its end with no error
+	next, stop = Just(1).Pull(context.Background())
+	defer stop()
+	_, _, _ = next()
+	for range 3 {
+		_, ok, err = next()
+		require.NoError(t, err)
+		require.False(t, ok)
+	}
+}
+
+func TestStream_Pull_StopClosesStream(t *testing.T) {
+	tu := newTestStreamUtil(t)
+	next, stop := FromSlice(makeRange(10)).WithAdditionalLifecycle(tu.AddLifecycleWatcher("1")).Pull(context.Background())
+	v, ok, err := next()
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, 0, v)
+	stop()
+	tu.requireAllWatchersVisited()
+	stop()
+}
@@ -0,0 +1,110 @@
+package stream
+
+import (
+	"container/heap"
+	"context"
+	"github.com/shpandrak/shpanstream"
+	"slices"
+)
+
+// TopK consumes the entire stream and returns its k largest elements according to the comparator, largest first.
+// Only k elements are kept in memory (a bounded heap), so it runs in O(n log k). Equal elements keep their
+// stream order.
+func TopK[T any](ctx context.Context, s Stream[T], k int, comparator shpanstream.Comparator[T]) ([]T, error) {
+	return collectTopK(ctx, s, k, comparator)
+}
+
+// BottomK consumes the entire stream and returns its k smallest elements according to the comparator, smallest
+// first. Like TopK, only k elements are kept in memory.
+func BottomK[T any](ctx context.Context, s Stream[T], k int, comparator shpanstream.Comparator[T]) ([]T, error) {
+	return collectTopK(ctx, s, k, func(one, other T) int {
+		return comparator(other, one)
+	})
+}
+
+// SortedLimit emits the k smallest elements of the stream in comparator order, the same as Sort followed by
+// Limit, but keeping only k elements in memory rather than materializing the entire stream.
+// Use a reversed comparator for the k largest elements.
+func SortedLimit[T any](s Stream[T], k int, comparator shpanstream.Comparator[T]) Stream[T] {
+	return newStreamFromCollector(s, func(ctx context.Context, src Stream[T]) ([]T, error) {
+		return BottomK(ctx, src, k, comparator)
+	})
+}
+
+func collectTopK[T any](ctx context.Context, s Stream[T], k int, comparator shpanstream.Comparator[T]) ([]T, error) {
+	if k <= 0 {
+		return nil, nil
+	}
+	h := &topKHeap[T]{comparator: comparator}
+	seq := 0
+	err := s.Consume(ctx, func(v T) {
+		e := topKEntry[T]{v: v, seq: seq}
+		seq++
+		if len(h.entries) < k {
+			heap.Push(h, e)
+		} else if h.less(h.entries[0], e) {
+			// Replacing the smallest of the top k elements
+			h.entries[0] = e
+			heap.Fix(h, 0)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Largest first, equal elements in stream order
+	slices.SortFunc(h.entries, func(a, b topKEntry[T]) int {
+		if h.less(a, b) {
+			return 1
+		}
+		if h.less(b, a) {
+			return -1
+		}
+		return 0
+	})
+	ret := make([]T, len(h.entries))
+	for i, e := range h.entries {
+		ret[i] = e.v
+	}
+	return ret, nil
+}
+
+type topKEntry[T any] struct {
+	v   T
+	seq int
+}
+
+// topKHeap is a min heap of the top elements, where among equal elements the later one is smaller (evicted first).
+type topKHeap[T any] struct {
+	comparator shpanstream.Comparator[T]
+	entries    []topKEntry[T]
+}
+
+func (h *topKHeap[T]) less(a, b topKEntry[T]) bool {
+	if c := h.comparator(a.v, b.v); c != 0 {
+		return c < 0
+	}
+	return a.seq > b.seq
+}
+
+func (h *topKHeap[T]) Len() int {
+	return len(h.entries)
+}
+
+func (h *topKHeap[T]) Less(i, j int) bool {
+	return h.less(h.entries[i], h.entries[j])
+}
+
+func (h *topKHeap[T]) Swap(i, j int) {
+	h.entries[i], h.entries[j] = h.entries[j], h.entries[i]
+}
+
+func (h *topKHeap[T]) Push(x any) {
+	h.entries = append(h.entries, x.(topKEntry[T]))
+}
+
+func (h *topKHeap[T]) Pop() any {
+	last := h.entries[len(h.entries)-1]
+	h.entries = h.entries[:len(h.entries)-1]
+	return last
+}
@@ -0,0 +1,81 @@
+package stream
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"github.com/stretchr/testify/require"
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+func TestTopK(t *testing.T) {
+	ctx := context.Background()
+	res, err := TopK(ctx, Just(5, 1, 9, 3, 7, 9, 2), 3, cmp.Compare[int])
+	require.NoError(t, err)
+	require.Equal(t, []int{9, 9, 7}, res)
+
+	res, err = BottomK(ctx, Just(5, 1, 9, 3, 7, 9, 2), 3, cmp.Compare[int])
+	require.NoError(t, err)
+	require.Equal(t, []int{1, 2, 3}, res)
+
+	// Fewer elements than k
+	res, err = TopK(ctx, Just(2, 1), 5, cmp.Compare[int])
+	require.NoError(t, err)
+	require.Equal(t, []int{2, 1}, res)
+
+	res, err = TopK(ctx, Just(2, 1), 0, cmp.Compare[int])
+	require.NoError(t, err)
+	require.Empty(t, res)
+}
+
+func TestTopK_MatchesSort(t *testing.T) {
+	r := rand.New(rand.NewSource(42))
+	values := make([]int, 1000)
+	for i := range values {
+		values[i] = r.Intn(100)
+	}
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+
+	res, err := BottomK(context.Background(), FromSlice(values), 17, cmp.Compare[int])
+	require.NoError(t, err)
+	require.Equal(t, sorted[:17], res)
+
+	res, err = TopK(context.Background(), FromSlice(values), 17, cmp.Compare[int])
+	require.NoError(t, err)
+	slices.Reverse(sorted)
+	require.Equal(t, sorted[:17], res)
+}
+
+func TestTopK_EqualElementsKeepStreamOrder(t *testing.T) {
+	type score struct {
+		name  string
+		score int
+	}
+	byScore := func(a, b score) int {
+		return cmp.Compare(a.score, b.score)
+	}
+	scores := Just(score{"a", 1}, score{"b", 3}, score{"c", 3}, score{"d", 2}, score{"e", 3})
+	res, err := TopK(context.Background(), scores, 2, byScore)
+	require.NoError(t, err)
+	require.Equal(t, []score{{"b", 3}, {"c", 3}}, res)
+}
+
+func TestTopK_Error(t *testing.T) {
+	boom := errors.New("boom")
+	_, err := TopK(context.Background(), errAt(FromSlice(makeRange(10)), 5, boom), 3, cmp.Compare[int])
+	require.ErrorIs(t, err, boom)
+}
+
+func TestSortedLimit(t *testing.T) {
+	s := SortedLimit(Just(5, 1, 9, 3, 7, 2), 3, cmp.Compare[int])
+	require.Equal(t, []int{1, 2, 3}, s.MustCollect())
+	require.Equal(t, []int{1, 2, 3}, s.MustCollect())
+	require.Equal(
+		t,
+		Sort(Just(5, 1, 9, 3, 7, 2), cmp.Compare[int]).Limit(4).MustCollect(),
+		SortedLimit(Just(5, 1, 9, 3, 7, 2), 4, cmp.Compare[int]).MustCollect(),
+	)
+}
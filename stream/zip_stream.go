@@ -2,8 +2,25 @@ package stream
 
 import (
 	"context"
+	"github.com/shpandrak/shpanstream"
+	"github.com/shpandrak/shpanstream/internal/util"
+	"io"
 )
 
+type ZipOption func(*zipConfig)
+
+type zipConfig struct {
+	longest bool
+}
+
+// WithZipLongestOption zips until the longest stream ends (rather than the shortest), padding the streams that
+// already ended with the zero value of their type.
+func WithZipLongestOption() ZipOption {
+	return func(cfg *zipConfig) {
+		cfg.longest = true
+	}
+}
+
 func ZipN[T any](s ...Stream[T]) Stream[[]T] {
 	if len(s) == 0 {
 		return Empty[[]T]()
@@ -23,3 +40,353 @@ func ZipN[T any](s ...Stream[T]) Stream[[]T] {
 
 		})
 }
+
+// ZipLongestN is like ZipN, but zips until the longest stream ends, padding the streams that already ended with
+// padding.
+func ZipLongestN[T any](padding T, s ...Stream[T]) Stream[[]T] {
+	if len(s) == 0 {
+		return Empty[[]T]()
+	}
+	return zipLongest(s, func(_ int) T {
+		return padding
+	})
+}
+
+// Zip2 zips two streams of different types into a stream of pairs, ending with the shortest stream
+// (see WithZipLongestOption).
+func Zip2[A any, B any](a Stream[A], b Stream[B], opts ...ZipOption) Stream[shpanstream.Tuple2[A, B]] {
+	cfg := newZipConfig(opts)
+	builder := &unsafeProviderBuilder{}
+	addStreamUnsafe(builder, a)
+	addStreamUnsafe(builder, b)
+	var za zipSource[A]
+	var zb zipSource[B]
+	return newUnsafeStream[shpanstream.Tuple2[A, B]](
+		builder,
+		func(ctx context.Context, builder *unsafeProviderBuilder) error {
+			if err := za.open(ctx, builder, 0); err != nil {
+				return err
+			}
+			return zb.open(ctx, builder, 1)
+		},
+		func(ctx context.Context, _ *unsafeProviderBuilder) (shpanstream.Tuple2[A, B], error) {
+			var ret shpanstream.Tuple2[A, B]
+			var err error
+			var endedA, endedB bool
+			if ret.A, endedA, err = za.pull(ctx, cfg); err != nil {
+				return shpanstream.Tuple2[A, B]{}, err
+			}
+			if ret.B, endedB, err = zb.pull(ctx, cfg); err != nil {
+				return shpanstream.Tuple2[A, B]{}, err
+			}
+			if endedA && endedB {
+				return shpanstream.Tuple2[A, B]{}, io.EOF
+			}
+			return ret, nil
+		},
+		nil,
+	)
+}
+
+// Zip3 zips three streams of different types into a stream of tuples, ending with the shortest stream
+// (see WithZipLongestOption).
+func Zip3[A any, B any, C any](
+	a Stream[A],
+	b Stream[B],
+	c Stream[C],
+	opts ...ZipOption,
+) Stream[shpanstream.Tuple3[A, B, C]] {
+	cfg := newZipConfig(opts)
+	builder := &unsafeProviderBuilder{}
+	addStreamUnsafe(builder, a)
+	addStreamUnsafe(builder, b)
+	addStreamUnsafe(builder, c)
+	var za zipSource[A]
+	var zb zipSource[B]
+	var zc zipSource[C]
+	return newUnsafeStream[shpanstream.Tuple3[A, B, C]](
+		builder,
+		func(ctx context.Context, builder *unsafeProviderBuilder) error {
+			if err := za.open(ctx, builder, 0); err != nil {
+				return err
+			}
+			if err := zb.open(ctx, builder, 1); err != nil {
+				return err
+			}
+			return zc.open(ctx, builder, 2)
+		},
+		func(ctx context.Context, _ *unsafeProviderBuilder) (shpanstream.Tuple3[A, B, C], error) {
+			var ret shpanstream.Tuple3[A, B, C]
+			var err error
+			var endedA, endedB, endedC bool
+			if ret.A, endedA, err = za.pull(ctx, cfg); err != nil {
+				return shpanstream.Tuple3[A, B, C]{}, err
+			}
+			if ret.B, endedB, err = zb.pull(ctx, cfg); err != nil {
+				return shpanstream.Tuple3[A, B, C]{}, err
+			}
+			if ret.C, endedC, err = zc.pull(ctx, cfg); err != nil {
+				return shpanstream.Tuple3[A, B, C]{}, err
+			}
+			if endedA && endedB && endedC {
+				return shpanstream.Tuple3[A, B, C]{}, io.EOF
+			}
+			return ret, nil
+		},
+		nil,
+	)
+}
+
+// Zip4 zips four streams of different types into a stream of tuples, ending with the shortest stream
+// (see WithZipLongestOption).
+func Zip4[A any, B any, C any, D any](
+	a Stream[A],
+	b Stream[B],
+	c Stream[C],
+	d Stream[D],
+	opts ...ZipOption,
+) Stream[shpanstream.Tuple4[A, B, C, D]] {
+	cfg := newZipConfig(opts)
+	builder := &unsafeProviderBuilder{}
+	addStreamUnsafe(builder, a)
+	addStreamUnsafe(builder, b)
+	addStreamUnsafe(builder, c)
+	addStreamUnsafe(builder, d)
+	var za zipSource[A]
+	var zb zipSource[B]
+	var zc zipSource[C]
+	var zd zipSource[D]
+	return newUnsafeStream[shpanstream.Tuple4[A, B, C, D]](
+		builder,
+		func(ctx context.Context, builder *unsafeProviderBuilder) error {
+			if err := za.open(ctx, builder, 0); err != nil {
+				return err
+			}
+			if err := zb.open(ctx, builder, 1); err != nil {
+				return err
+			}
+			if err := zc.open(ctx, builder, 2); err != nil {
+				return err
+			}
+			return zd.open(ctx, builder, 3)
+		},
+		func(ctx context.Context, _ *unsafeProviderBuilder) (shpanstream.Tuple4[A, B, C, D], error) {
+			var ret shpanstream.Tuple4[A, B, C, D]
+			var err error
+			var endedA, endedB, endedC, endedD bool
+			if ret.A, endedA, err = za.pull(ctx, cfg); err != nil {
+				return shpanstream.Tuple4[A, B, C, D]{}, err
+			}
+			if ret.B, endedB, err = zb.pull(ctx, cfg); err != nil {
+				return shpanstream.Tuple4[A, B, C, D]{}, err
+			}
+			if ret.C, endedC, err = zc.pull(ctx, cfg); err != nil {
+				return shpanstream.Tuple4[A, B, C, D]{}, err
+			}
+			if ret.D, endedD, err = zd.pull(ctx, cfg); err != nil {
+				return shpanstream.Tuple4[A, B, C, D]{}, err
+			}
+			if endedA && endedB && endedC && endedD {
+				return shpanstream.Tuple4[A, B, C, D]{}, io.EOF
+			}
+			return ret, nil
+		},
+		nil,
+	)
+}
+
+// Zip5 zips five streams of different types into a stream of tuples, ending with the shortest stream
+// (see WithZipLongestOption).
+func Zip5[A any, B any, C any, D any, E any](
+	a Stream[A],
+	b Stream[B],
+	c Stream[C],
+	d Stream[D],
+	e Stream[E],
+	opts ...ZipOption,
+) Stream[shpanstream.Tuple5[A, B, C, D, E]] {
+	cfg := newZipConfig(opts)
+	builder := &unsafeProviderBuilder{}
+	addStreamUnsafe(builder, a)
+	addStreamUnsafe(builder, b)
+	addStreamUnsafe(builder, c)
+	addStreamUnsafe(builder, d)
+	addStreamUnsafe(builder, e)
+	var za zipSource[A]
+	var zb zipSource[B]
+	var zc zipSource[C]
+	var zd zipSource[D]
+	var ze zipSource[E]
+	return newUnsafeStream[shpanstream.Tuple5[A, B, C, D, E]](
+		builder,
+		func(ctx context.Context, builder *unsafeProviderBuilder) error {
+			if err := za.open(ctx, builder, 0); err != nil {
+				return err
+			}
+			if err := zb.open(ctx, builder, 1); err != nil {
+				return err
+			}
+			if err := zc.open(ctx, builder, 2); err != nil {
+				return err
+			}
+			if err := zd.open(ctx, builder, 3); err != nil {
+				return err
+			}
+			return ze.open(ctx, builder, 4)
+		},
+		func(ctx context.Context, _ *unsafeProviderBuilder) (shpanstream.Tuple5[A, B, C, D, E], error) {
+			var ret shpanstream.Tuple5[A, B, C, D, E]
+			var err error
+			var endedA, endedB, endedC, endedD, endedE bool
+			if ret.A, endedA, err = za.pull(ctx, cfg); err != nil {
+				return shpanstream.Tuple5[A, B, C, D, E]{}, err
+			}
+			if ret.B, endedB, err = zb.pull(ctx, cfg); err != nil {
+				return shpanstream.Tuple5[A, B, C, D, E]{}, err
+			}
+			if ret.C, endedC, err = zc.pull(ctx, cfg); err != nil {
+				return shpanstream.Tuple5[A, B, C, D, E]{}, err
+			}
+			if ret.D, endedD, err = zd.pull(ctx, cfg); err != nil {
+				return shpanstream.Tuple5[A, B, C, D, E]{}, err
+			}
+			if ret.E, endedE, err = ze.pull(ctx, cfg); err != nil {
+				return shpanstream.Tuple5[A, B, C, D, E]{}, err
+			}
+			if endedA && endedB && endedC && endedD && endedE {
+				return shpanstream.Tuple5[A, B, C, D, E]{}, io.EOF
+			}
+			return ret, nil
+		},
+		nil,
+	)
+}
+
+// Pairwise emits each pair of consecutive elements of the stream, e.g. for computing deltas.
+// A stream of n elements results in n-1 pairs.
+func Pairwise[T any](s Stream[T]) Stream[shpanstream.Tuple2[T, T]] {
+	var prev *T
+	return NewDownStreamSimple(
+		s,
+		func(ctx context.Context, srcProviderFunc ProviderFunc[T]) (shpanstream.Tuple2[T, T], error) {
+			if prev == nil {
+				first, err := srcProviderFunc(ctx)
+				if err != nil {
+					return shpanstream.Tuple2[T, T]{}, err
+				}
+				prev = &first
+			}
+			// Always check if the context is done before trying to pull the next element
+			if ctx.Err() != nil {
+				return shpanstream.Tuple2[T, T]{}, ctx.Err()
+			}
+			curr, err := srcProviderFunc(ctx)
+			if err != nil {
+				return shpanstream.Tuple2[T, T]{}, err
+			}
+			ret := shpanstream.Tuple2[T, T]{A: *prev, B: curr}
+			prev = &curr
+			return ret, nil
+		},
+		func(_ context.Context, _ ProviderFunc[T]) error {
+			// Reset the state to support re-consumption (double collection)
+			prev = nil
+			return nil
+		},
+		nil,
+	)
+}
+
+func newZipConfig(opts []ZipOption) zipConfig {
+	var cfg zipConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+// zipSource is one of the typed sources of Zip2..Zip5, pulled without boxing its elements.
+type zipSource[T any] struct {
+	provider ProviderFunc[T]
+
+	// Per-consumption state, (re)initialised in open.
+	ended bool
+}
+
+func (zs *zipSource[T]) open(ctx context.Context, b *unsafeProviderBuilder, idx int) error {
+	var err error
+	zs.provider, err = openSubStreamUnsafe[T](ctx, b, idx)
+	zs.ended = false
+	return err
+}
+
+// pull pulls the next element of the source. When zipping until the longest stream ends, a source that already
+// ended is padded with the zero value and reported as ended, otherwise its io.EOF is returned as is.
+func (zs *zipSource[T]) pull(ctx context.Context, cfg zipConfig) (T, bool, error) {
+	if zs.ended {
+		return util.DefaultValue[T](), true, nil
+	}
+	// Always check if the context is done before trying to pull elements from a stream
+	if ctx.Err() != nil {
+		return util.DefaultValue[T](), false, ctx.Err()
+	}
+	v, err := zs.provider(ctx)
+	if err != nil {
+		if err == io.EOF && cfg.longest {
+			zs.ended = true
+			return util.DefaultValue[T](), true, nil
+		}
+		return util.DefaultValue[T](), false, err
+	}
+	return v, false, nil
+}
+
+func zipLongest[T any](streams []Stream[T], padding func(i int) T) Stream[[]T] {
+	zp := &zipLongestProvider[T]{padding: padding}
+	return NewDownMultiStream[T, []T](streams, zp)
+}
+
+type zipLongestProvider[T any] struct {
+	padding func(i int) T
+
+	// Per-consumption state, (re)initialised in Open.
+	ended []bool
+}
+
+func (zp *zipLongestProvider[T]) Open(_ context.Context, srcProviderFuncs []ProviderFunc[T]) error {
+	zp.ended = make([]bool, len(srcProviderFuncs))
+	return nil
+}
+
+func (zp *zipLongestProvider[T]) Emit(ctx context.Context, srcProviderFuncs []ProviderFunc[T]) ([]T, error) {
+	result := make([]T, len(srcProviderFuncs))
+	allEnded := true
+	for i, p := range srcProviderFuncs {
+		if zp.ended[i] {
+			result[i] = zp.padding(i)
+			continue
+		}
+		// Always check if the context is done before trying to pull elements from a stream
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		v, err := p(ctx)
+		if err != nil {
+			if err != io.EOF {
+				return nil, err
+			}
+			zp.ended[i] = true
+			result[i] = zp.padding(i)
+			continue
+		}
+		allEnded = false
+		result[i] = v
+	}
+	if allEnded {
+		return nil, io.EOF
+	}
+	return result, nil
+}
+
+func (zp *zipLongestProvider[T]) Close() {
+}
@@ -3,6 +3,7 @@ package stream
 import (
 	"context"
 	"errors"
+	"github.com/shpandrak/shpanstream"
 	"github.com/stretchr/testify/require"
 	"testing"
 )
@@ -154,3 +155,93 @@ func TestZipN_StreamWithError(t *testing.T) {
 
 	tu.requireAllWatchersVisited()
 }
+
+func TestZipLongestN(t *testing.T) {
+	s := ZipLongestN(
+		-1,
+		Just(1, 4, 7, 10),
+		Just(2, 5),
+		Empty[int](),
+	)
+	expected := [][]int{
+		{1, 2, -1},
+		{4, 5, -1},
+		{7, -1, -1},
+		{10, -1, -1},
+	}
+	require.Equal(t, expected, s.MustCollect())
+	require.Equal(t, expected, s.MustCollect())
+
+	require.Len(t, ZipLongestN(0, Empty[int](), Empty[int]()).MustCollect(), 0)
+}
+
+func TestZip2(t *testing.T) {
+	require.Equal(
+		t,
+		[]shpanstream.Tuple2[int, string]{{A: 1, B: "a"}, {A: 2, B: "b"}},
+		Zip2(Just(1, 2, 3), Just("a", "b")).MustCollect(),
+	)
+
+	// Longest pads with zero values, including nil for interface types
+	require.Equal(
+		t,
+		[]shpanstream.Tuple2[error, string]{{A: errors.New("e"), B: "a"}, {A: nil, B: "b"}},
+		Zip2(Just(errors.New("e")), Just("a", "b"), WithZipLongestOption()).MustCollect(),
+	)
+
+	// The ended sources are reset for re-consumption (double collection)
+	s := Zip2(Just(1), Just("a", "b"), WithZipLongestOption())
+	expected := []shpanstream.Tuple2[int, string]{{A: 1, B: "a"}, {A: 0, B: "b"}}
+	require.Equal(t, expected, s.MustCollect())
+	require.Equal(t, expected, s.MustCollect())
+}
+
+func TestZip4(t *testing.T) {
+	require.Equal(
+		t,
+		[]shpanstream.Tuple4[int, string, bool, float64]{{A: 1, B: "a", C: true, D: 1.5}},
+		Zip4(Just(1, 2), Just("a"), Just(true, false), Just(1.5, 2.5)).MustCollect(),
+	)
+}
+
+func TestZip3(t *testing.T) {
+	require.Equal(
+		t,
+		[]shpanstream.Tuple3[int, string, bool]{{A: 1, B: "a", C: true}, {A: 2, B: "", C: false}},
+		Zip3(Just(1, 2), Just("a"), Just(true), WithZipLongestOption()).MustCollect(),
+	)
+}
+
+func TestZip5(t *testing.T) {
+	require.Equal(
+		t,
+		[]shpanstream.Tuple5[int, string, bool, float64, int]{{A: 1, B: "a", C: true, D: 1.5, E: 5}},
+		Zip5(Just(1, 2), Just("a"), Just(true), Just(1.5), Just(5, 6)).MustCollect(),
+	)
+}
+
+func TestZip2_ResourceMgmt(t *testing.T) {
+	tu := newTestStreamUtil(t)
+	_, err := Zip2(
+		Just(1, 2).WithAdditionalLifecycle(tu.AddLifecycleWatcher("1")),
+		MapWithErr(Just(1, 2), func(i int) (string, error) {
+			if i == 2 {
+				return "", errors.New("test error")
+			}
+			return "a", nil
+		}).WithAdditionalLifecycle(tu.AddLifecycleWatcher("2")),
+		WithZipLongestOption(),
+	).Collect(context.Background())
+	require.Error(t, err)
+	tu.requireAllWatchersVisited()
+}
+
+func TestPairwise(t *testing.T) {
+	s := Pairwise(Just(1, 3, 6, 10))
+	expected := []shpanstream.Tuple2[int, int]{{A: 1, B: 3}, {A: 3, B: 6}, {A: 6, B: 10}}
+	require.Equal(t, expected, s.MustCollect())
+	require.Equal(t, expected, s.MustCollect())
+
+	require.Len(t, Pairwise(Just(1)).MustCollect(), 0)
+	require.Len(t, Pairwise(Empty[int]()).MustCollect(), 0)
+}
@@ -16,6 +16,13 @@ type Result[T any] struct {
 	Err   error
 }
 
+// Number is a constraint for the numeric types.
+type Number interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr |
+		~float32 | ~float64
+}
+
 type Comparable[T any] interface {
 	Compare(other T) int
 }
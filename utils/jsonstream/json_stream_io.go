@@ -8,6 +8,8 @@ import (
 	"io"
 )
 
+// StreamJsonToWriter writes the stream to the writer as a json array. For other formats (e.g. newline delimited
+// json), see Stream.EncodeTo.
 func StreamJsonToWriter[T any](ctx context.Context, w io.Writer, stream stream.Stream[T]) error {
 	return StreamJsonToWriterWithInit(ctx, w, stream, func() error {
 		return nil
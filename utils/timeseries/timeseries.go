@@ -2,14 +2,12 @@ package timeseries
 
 import (
 	"fmt"
+	"github.com/shpandrak/shpanstream"
 	"time"
 )
 
-type Number interface {
-	~int | ~int8 | ~int16 | ~int32 | ~int64 |
-		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr |
-		~float32 | ~float64
-}
+// Number is the numeric constraint of the time series values, see shpanstream.Number.
+type Number = shpanstream.Number
 
 type TsRecord[T any] struct {
 	Timestamp time.Time `json:"timestamp"`
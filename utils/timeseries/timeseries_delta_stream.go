@@ -2,28 +2,22 @@ package timeseries
 
 import (
 	"fmt"
+	"github.com/shpandrak/shpanstream"
 	"github.com/shpandrak/shpanstream/stream"
 )
 
 func DeltaStream[N Number](s stream.Stream[TsRecord[N]]) stream.Stream[TsRecord[N]] {
-	var prevItem *TsRecord[N]
-	return stream.MapWhileFilteringWithErr(
-		s,
-		func(item TsRecord[N]) (*TsRecord[N], error) {
-			if prevItem == nil {
-				prevItem = &item
-				return nil, nil
-			} else {
-				if !item.Timestamp.After(prevItem.Timestamp) {
-					return nil, fmt.Errorf("item timestamp %s is not after previous item timestamp %s", item.Timestamp, prevItem.Timestamp)
-				}
-				ret := &TsRecord[N]{
-					Value:     item.Value - prevItem.Value,
-					Timestamp: item.Timestamp,
-				}
-				prevItem = &item
-				return ret, nil
+	return stream.MapWithErr(
+		stream.Pairwise(s),
+		func(pair shpanstream.Tuple2[TsRecord[N], TsRecord[N]]) (TsRecord[N], error) {
+			prevItem, item := pair.Unpack()
+			if !item.Timestamp.After(prevItem.Timestamp) {
+				return TsRecord[N]{}, fmt.Errorf("item timestamp %s is not after previous item timestamp %s", item.Timestamp, prevItem.Timestamp)
 			}
+			return TsRecord[N]{
+				Value:     item.Value - prevItem.Value,
+				Timestamp: item.Timestamp,
+			}, nil
 		},
 	)
 }
@@ -0,0 +1,212 @@
+package queryopenapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/shpandrak/shpanstream/stream"
+	"github.com/shpandrak/shpanstream/utils/jsonstream"
+	"github.com/shpandrak/shpanstream/utils/timeseries"
+	"github.com/shpandrak/shpanstream/utils/timeseries/tsquery"
+	"github.com/shpandrak/shpanstream/utils/timeseries/tsquery/aggregation"
+	"github.com/shpandrak/shpanstream/utils/timeseries/tsquery/datasource"
+)
+
+const (
+	ExecuteQueryPath       = "/api/commands/execute-query"
+	ExecuteAggregationPath = "/api/commands/execute-aggregation"
+)
+
+// QueryHttpHandler serves the tsquery-swagger.yaml commands end-to-end: it parses the request using the
+// PluginApiParser, executes the parsed query and writes the result back in the API format.
+// Report based queries and aggregations (fromReport datasources) are executed through the same endpoints.
+// Query results are streamed to the client, so the time series data is never fully loaded into memory.
+type QueryHttpHandler struct {
+	plugin PluginApiParser
+	mux    *http.ServeMux
+}
+
+// NewQueryHttpHandler creates a handler serving the tsquery commands, plugin may be nil when no custom
+// datasources, filters or fields are used.
+func NewQueryHttpHandler(plugin PluginApiParser) *QueryHttpHandler {
+	h := &QueryHttpHandler{plugin: plugin, mux: http.NewServeMux()}
+	h.mux.HandleFunc("POST "+ExecuteQueryPath, h.HandleExecuteQuery)
+	h.mux.HandleFunc("POST "+ExecuteAggregationPath, h.HandleExecuteAggregation)
+	return h
+}
+
+func (h *QueryHttpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+// HandleExecuteQuery handles the executeQuery operation, it can be used directly when mounting the
+// command on a custom route.
+func (h *QueryHttpHandler) HandleExecuteQuery(w http.ResponseWriter, r *http.Request) {
+	var args ApiExecuteQueryCommandArgs
+	if err := decodeRequestBody(r, &args); err != nil {
+		writeHttpError(w, err)
+		return
+	}
+	result, err := h.ExecuteQuery(r.Context(), args)
+	if err != nil {
+		writeHttpError(w, err)
+		return
+	}
+	if started, err := streamQueryResult(r.Context(), w, result); err != nil {
+		// Headers are only written once the first element is ready, so an error before that can still be
+		// reported properly. Once the body started streaming, all we can do is abort and log.
+		if !started {
+			writeHttpError(w, err)
+			return
+		}
+		slog.Error(fmt.Sprintf("failed streaming query result for %s: %v", result.Meta().Urn(), err))
+	}
+}
+
+// HandleExecuteAggregation handles the executeAggregation operation, it can be used directly when mounting
+// the command on a custom route.
+func (h *QueryHttpHandler) HandleExecuteAggregation(w http.ResponseWriter, r *http.Request) {
+	var args ApiExecuteAggregationCommandArgs
+	if err := decodeRequestBody(r, &args); err != nil {
+		writeHttpError(w, err)
+		return
+	}
+	result, err := h.ExecuteAggregation(r.Context(), args)
+	if err != nil {
+		writeHttpError(w, err)
+		return
+	}
+	rawJson, err := json.Marshal(result)
+	if err != nil {
+		writeHttpError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(rawJson); err != nil {
+		slog.Error(fmt.Sprintf("failed writing aggregation result: %v", err))
+	}
+}
+
+// ExecuteQuery parses and executes the query command, the data stream of the returned result is not
+// materialized yet.
+func (h *QueryHttpHandler) ExecuteQuery(ctx context.Context, args ApiExecuteQueryCommandArgs) (datasource.Result, error) {
+	ds, err := ParseDatasource(NewParsingContext(ctx, h.plugin), args.Datasource)
+	if err != nil {
+		return datasource.Result{}, badInputErrorWrap("executeQuery", err, "failed parsing datasource")
+	}
+	return ds.Execute(ctx, args.From, args.To)
+}
+
+// ExecuteAggregation parses and executes the aggregation command, materializing the aggregated values.
+func (h *QueryHttpHandler) ExecuteAggregation(ctx context.Context, args ApiExecuteAggregationCommandArgs) (ApiAggregationResult, error) {
+	agg, err := ParseAggregation(NewParsingContext(ctx, h.plugin), args.Aggregation)
+	if err != nil {
+		return ApiAggregationResult{}, badInputErrorWrap("executeAggregation", err, "failed parsing aggregation")
+	}
+	result, err := agg.Execute(ctx, args.From, args.To)
+	if err != nil {
+		return ApiAggregationResult{}, err
+	}
+	values, err := result.Fields().Get(ctx)
+	if err != nil {
+		return ApiAggregationResult{}, err
+	}
+	return ToApiAggregationResult(result.FieldsMeta(), values)
+}
+
+// ToApiQueryFieldMeta converts field metadata to its API representation.
+func ToApiQueryFieldMeta(fm tsquery.FieldMeta) ApiQueryFieldMeta {
+	apiMeta := ApiQueryFieldMeta{
+		Uri:            fm.Urn(),
+		DataType:       fm.DataType(),
+		MetricKind:     fm.RawMetricKind(),
+		Required:       fm.Required(),
+		Unit:           fm.Unit(),
+		CustomMetadata: fm.CustomMeta(),
+	}
+	if sp := fm.SamplePeriod(); sp != nil {
+		apiMeta.SamplePeriod = sp.String()
+	}
+	return apiMeta
+}
+
+// ToApiMeasurementValue converts a time series record to its API representation.
+func ToApiMeasurementValue(r timeseries.TsRecord[any]) ApiMeasurementValue {
+	return ApiMeasurementValue{Timestamp: r.Timestamp, Value: r.Value}
+}
+
+// ToApiAggregationResult converts aggregated values and their (parallel) metadata to the API representation.
+func ToApiAggregationResult(fieldsMeta []tsquery.FieldMeta, values []aggregation.AggregatedValue) (ApiAggregationResult, error) {
+	if len(fieldsMeta) != len(values) {
+		return ApiAggregationResult{}, fmt.Errorf(
+			"aggregation returned %d values for %d fields",
+			len(values),
+			len(fieldsMeta),
+		)
+	}
+	fields := make([]ApiAggregatedField, len(values))
+	for i, v := range values {
+		fields[i] = ApiAggregatedField{
+			Meta:      ToApiQueryFieldMeta(fieldsMeta[i]),
+			Timestamp: v.Timestamp,
+		}
+		if v.Value != nil {
+			value := v.Value
+			fields[i].Value = &value
+		}
+	}
+	return ApiAggregationResult{Fields: fields}, nil
+}
+
+// streamQueryResult writes the result object around the streamed data array: {"meta":...,"data":[...]}.
+// It reports whether the response has already started when an error occurs.
+func streamQueryResult(ctx context.Context, w http.ResponseWriter, result datasource.Result) (bool, error) {
+	rawMeta, err := json.Marshal(ToApiQueryFieldMeta(result.Meta()))
+	if err != nil {
+		return false, err
+	}
+	started := false
+	err = jsonstream.StreamJsonToWriterWithInit(
+		ctx,
+		w,
+		stream.Map(result.Data(), ToApiMeasurementValue),
+		func() error {
+			started = true
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			_, err := fmt.Fprintf(w, `{"meta":%s,"data":`, rawMeta)
+			return err
+		},
+	)
+	if err != nil {
+		return started, err
+	}
+	_, err = w.Write([]byte("}"))
+	return true, err
+}
+
+func decodeRequestBody(r *http.Request, target any) error {
+	if err := json.NewDecoder(r.Body).Decode(target); err != nil {
+		return badInputErrorWrap(r.URL.Path, err, "failed decoding request body")
+	}
+	return nil
+}
+
+// writeHttpError reports invalid queries (including any parsing failure) as bad requests, and any other
+// failure as an internal error.
+func writeHttpError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	var iqe invalidQueryError
+	if errors.As(err, &iqe) {
+		status = http.StatusBadRequest
+	} else if errors.Is(err, context.Canceled) {
+		// The client went away, nobody is listening to the response anyway
+		return
+	}
+	http.Error(w, err.Error(), status)
+}
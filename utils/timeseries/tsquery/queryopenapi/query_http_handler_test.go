@@ -0,0 +1,132 @@
+package queryopenapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/shpandrak/shpanstream/utils/timeseries/tsquery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func postJson(t *testing.T, h http.Handler, path string, body any) *httptest.ResponseRecorder {
+	t.Helper()
+	rawBody, err := json.Marshal(body)
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(rawBody))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func helperStaticApiDatasource(t *testing.T, values []float64) ApiQueryDatasource {
+	t.Helper()
+	baseTime := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	data := make([]ApiMeasurementValue, len(values))
+	for i, v := range values {
+		data[i] = ApiMeasurementValue{Timestamp: baseTime.Add(time.Duration(i) * time.Hour), Value: v}
+	}
+	var apiDs ApiQueryDatasource
+	require.NoError(t, apiDs.FromApiStaticQueryDatasource(ApiStaticQueryDatasource{
+		Type: "static",
+		FieldMeta: ApiQueryFieldMeta{
+			Uri:      "temperature",
+			DataType: tsquery.DataTypeDecimal,
+			Required: true,
+			Unit:     "celsius",
+		},
+		Data: data,
+	}))
+	return apiDs
+}
+
+func TestQueryHttpHandler_ExecuteQuery(t *testing.T) {
+	baseTime := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	rec := postJson(t, NewQueryHttpHandler(nil), ExecuteQueryPath, ApiExecuteQueryCommandArgs{
+		Datasource: helperStaticApiDatasource(t, []float64{1.5, 2.5, 3.5}),
+		From:       baseTime,
+		To:         baseTime.Add(2 * time.Hour),
+	})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+	var result ApiQueryResult
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+	assert.Equal(t, "temperature", result.Meta.Uri)
+	assert.Equal(t, tsquery.DataTypeDecimal, result.Meta.DataType)
+	assert.Equal(t, "celsius", result.Meta.Unit)
+	assert.True(t, result.Meta.Required)
+
+	// "to" is exclusive
+	require.Len(t, result.Data, 2)
+	assert.Equal(t, baseTime, result.Data[0].Timestamp)
+	assert.Equal(t, 1.5, result.Data[0].Value)
+	assert.Equal(t, baseTime.Add(time.Hour), result.Data[1].Timestamp)
+	assert.Equal(t, 2.5, result.Data[1].Value)
+}
+
+func TestQueryHttpHandler_ExecuteQuery_EmptyResult(t *testing.T) {
+	baseTime := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	rec := postJson(t, NewQueryHttpHandler(nil), ExecuteQueryPath, ApiExecuteQueryCommandArgs{
+		Datasource: helperStaticApiDatasource(t, nil),
+		From:       baseTime,
+		To:         baseTime.Add(time.Hour),
+	})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var result ApiQueryResult
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+	assert.Equal(t, "temperature", result.Meta.Uri)
+	assert.NotNil(t, result.Data)
+	assert.Empty(t, result.Data)
+}
+
+func TestQueryHttpHandler_ExecuteAggregation(t *testing.T) {
+	baseTime := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	rec := postJson(t, NewQueryHttpHandler(nil), ExecuteAggregationPath, ApiExecuteAggregationCommandArgs{
+		Aggregation: helperStaticDsAgg(t, []float64{10, 20, 30}),
+		From:        baseTime,
+		To:          baseTime.Add(24 * time.Hour),
+	})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var result ApiAggregationResult
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+	require.Len(t, result.Fields, 2)
+
+	assert.Equal(t, "total", result.Fields[0].Meta.Uri)
+	require.NotNil(t, result.Fields[0].Value)
+	assert.Equal(t, 60.0, *result.Fields[0].Value)
+
+	assert.Equal(t, "cnt", result.Fields[1].Meta.Uri)
+	require.NotNil(t, result.Fields[1].Value)
+	assert.Equal(t, 3.0, *result.Fields[1].Value)
+}
+
+func TestQueryHttpHandler_BadRequest(t *testing.T) {
+	h := NewQueryHttpHandler(nil)
+
+	req := httptest.NewRequest(http.MethodPost, ExecuteQueryPath, bytes.NewReader([]byte("{not json")))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	// Unknown datasource type, not supported by the (nil) plugin parser
+	rec = postJson(t, h, ExecuteQueryPath, map[string]any{
+		"datasource": map[string]any{"type": "postgres"},
+		"from":       time.Now(),
+		"to":         time.Now(),
+	})
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestQueryHttpHandler_MethodNotAllowed(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, ExecuteQueryPath, nil)
+	rec := httptest.NewRecorder()
+	NewQueryHttpHandler(nil).ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
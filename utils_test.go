@@ -26,3 +26,15 @@ func TestUnpackResult(t *testing.T) {
 	_, err = UnpackResult(Result[string]{Err: boom})
 	require.ErrorIs(t, err, boom)
 }
+
+func TestPanicError(t *testing.T) {
+	boom := errors.New("boom")
+	pe := NewPanicError(boom)
+	require.ErrorIs(t, pe, boom)
+	require.EqualError(t, pe, "shpanstream recovered error: boom")
+	require.NotEmpty(t, pe.Stack)
+
+	pe = NewPanicError("oops")
+	require.Nil(t, pe.Unwrap())
+	require.EqualError(t, pe, "shpanstream recovered error value: oops")
+}